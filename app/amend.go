@@ -0,0 +1,46 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var amendCmd = &cobra.Command{
+	Use:   "amend <manifest list>",
+	Short: "add, remove and replace entries of an existing manifest list",
+	Long: `Fetch an existing manifest list, add, remove and replace its entries, then push it back.
+
+  manifest amend registry/app:1.4 --add registry/app:1.4-s390x --remove linux/arm/v6 \
+      --replace linux/arm64/v8=registry/app:1.4-arm64v8-rebuild
+
+The manifest list is only overwritten if it did not change in the meantime, unless --force or --if-digest is given.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		amendment := &manifest.Amendment{
+			Remove: getStringSlice(cmd.Flags(), "remove"),
+		}
+		for _, img := range getStringSlice(cmd.Flags(), "add") {
+			amendment.Add = append(amendment.Add, manifest.Source{Image: img})
+		}
+		for _, s := range getStringSlice(cmd.Flags(), "replace") {
+			replacement, err := manifest.ParseReplacement(s)
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			amendment.Replace = append(amendment.Replace, replacement)
+		}
+
+		auth := getAuth(cmd.Flags())
+		plan, oldDigest, err := manifest.PlanAmend(auth, getCreateOptions(cmd.Flags()), args[0], amendment)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		fmt.Printf("Old digest: %s\n", oldDigest)
+		fmt.Printf("New digest: %s\n", plan.Digest)
+		pushPlan(cmd.Flags(), plan)
+	},
+}
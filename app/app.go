@@ -1,12 +1,20 @@
 package app
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/distribution/manifest/manifestlist"
 
 	"github.com/sakeven/manifest/pkg/manifest"
+	"github.com/sakeven/manifest/pkg/platforms"
 	"github.com/sakeven/manifest/pkg/reference"
 
 	log "github.com/Sirupsen/logrus"
@@ -21,7 +29,63 @@ func Run() {
 	rootCmd.PersistentFlags().String("username", "", "Username to access docker repository")
 	rootCmd.PersistentFlags().String("password", "", "Password to access docker repository")
 	rootCmd.PersistentFlags().String("cfg", config.Dir(), "docker configure file to access docker repository")
-	rootCmd.AddCommand(createCmd, inspectCmd, annotateCmd)
+	addCreateFlags(createCmd.Flags())
+	createCmd.Flags().StringSlice("platforms", nil, "Platforms to expand --template for, like linux/amd64,linux/arm64/v8")
+	createCmd.Flags().String("template", "", "Go template of source repositories, which can use .OS, .Arch, .Variant and .OSVersion of each platform")
+	createCmd.Flags().Bool("ignore-missing", false, "Skip platforms whose source repository expanded from --template does not exist")
+	addCreateFlags(fromSpecCmd.Flags())
+	addCreateFlags(amendCmd.Flags())
+	amendCmd.Flags().StringSlice("add", nil, "Image to add to the manifest list, can be repeated")
+	amendCmd.Flags().StringSlice("remove", nil, "Entry to remove from the manifest list, by platform or digest, can be repeated")
+	amendCmd.Flags().StringSlice("replace", nil, "Entry to replace as <platform>=<image>, can be repeated")
+	validateCmd.Flags().Bool("schema", false, "Print the JSON Schema of spec files")
+	pushCmd.AddCommand(fromSpecCmd)
+	addCreateFlags(annotateCmd.Flags())
+	annotateCmd.Flags().String("entry", "", "Entry to annotate, by digest or current platform like linux/arm")
+	annotateCmd.Flags().String("os", "", "Operating system of the entry")
+	annotateCmd.Flags().String("arch", "", "Architecture of the entry")
+	annotateCmd.Flags().String("variant", "", "CPU variant of the entry")
+	annotateCmd.Flags().String("os-version", "", "Operating system version of the entry")
+	annotateCmd.Flags().StringSlice("os-features", nil, "Operating system features of the entry")
+	annotateCmd.Flags().StringSlice("features", nil, "CPU features of the entry")
+	annotateCmd.Flags().String("to-tag", "", "Tag to push the annotated manifest list to, instead of its own tag")
+	annotateCmd.Flags().Bool("no-check", false, "Do not check the platform against the image configuration of the entry")
+	splitCmd.Flags().String("tag-template", manifest.DefaultSplitTagTemplate, "Go template of the tag of each entry")
+	splitCmd.Flags().String("repo", "", "Repository to push the entries to, on the same registry, instead of the repository of the manifest list")
+	splitCmd.Flags().Bool("force", false, "Overwrite tags which already point to a different manifest")
+	addCreateFlags(wrapCmd.Flags())
+	wrapCmd.Flags().String("os", "", "Operating system of the entry, instead of the one of the image")
+	wrapCmd.Flags().String("arch", "", "Architecture of the entry, instead of the one of the image")
+	wrapCmd.Flags().String("variant", "", "CPU variant of the entry, instead of the one of the image")
+	wrapCmd.Flags().String("os-version", "", "Operating system version of the entry, instead of the one of the image")
+	copyCmd.Flags().StringSlice("platform", nil, "Platforms of the entries of a manifest list to copy, like linux/amd64,linux/arm64")
+	copyCmd.Flags().Bool("all-tags", false, "Copy all tags of the source repository")
+	copyCmd.Flags().Bool("force", false, "Overwrite tags which already point to a different manifest")
+	convertCmd.Flags().String("to", "", "Format to convert to, oci or docker")
+	convertCmd.Flags().String("dest", "", "Image to push the converted manifest list to, instead of converting it in place")
+	convertCmd.Flags().Bool("force", false, "Overwrite the destination tag even if it points to another manifest")
+	syncCmd.Flags().Duration("interval", 0, "Sync again at this interval, like 1h, instead of once")
+	syncCmd.Flags().String("report", "", "File to write a JSON report of each sync to")
+	promoteCmd.Flags().String("expect-digest", "", "Digest the source must have")
+	promoteCmd.Flags().StringSlice("require-platforms", nil, "Platforms the source must have, like linux/amd64,linux/arm64")
+	promoteCmd.Flags().StringSlice("require-labels", nil, "Labels the configuration of every image must have")
+	promoteCmd.Flags().Bool("annotate", false, "Record the checks as annotations on the promoted OCI image index, which changes its digest")
+	promoteCmd.Flags().Bool("force", false, "Promote even if the destination tag points to another manifest")
+	promoteCmd.Flags().String("report", "", "File to write a JSON report of the checks to")
+	diffCmd.Flags().Bool("json", false, "Print the differences as JSON")
+	verifyCmd.Flags().Bool("layers", false, "Check that every layer exists")
+	verifyCmd.Flags().Int("jobs", manifest.DefaultJobs, "Number of entries verified at a time")
+	verifyCmd.Flags().Bool("json", false, "Print the report as JSON")
+	auditCmd.Flags().Bool("deep", false, "Download and re-hash every blob, instead of only checking its existence and size")
+	auditCmd.Flags().Int("jobs", manifest.DefaultJobs, "Number of blobs audited at a time")
+	auditCmd.Flags().String("state", "", "File recording the audited blobs, to resume an interrupted audit")
+	auditCmd.Flags().Bool("json", false, "Print the report as JSON")
+	checkPlatformsCmd.Flags().StringSlice("platforms", nil, "Platforms the manifest list must have, no more and no less, like linux/amd64,linux/arm64/v8")
+	inspectCmd.Flags().String("platform", "", "Platform to pick the entry of a manifest list of, like linux/arm64")
+	lintCmd.Flags().String("rules", "", "YAML or JSON file configuring the lint rules")
+	lintCmd.Flags().Bool("json", false, "Print the findings as JSON")
+	lintCmd.Flags().Bool("list-rules", false, "List the lint rules with their default severity")
+	rootCmd.AddCommand(createCmd, pushCmd, validateCmd, amendCmd, splitCmd, wrapCmd, copyCmd, convertCmd, syncCmd, promoteCmd, diffCmd, verifyCmd, checkPlatformsCmd, auditCmd, lintCmd, inspectCmd, annotateCmd)
 	rootCmd.Execute()
 }
 
@@ -39,40 +103,185 @@ var rootCmd = &cobra.Command{
 var createCmd = &cobra.Command{
 	Use:   "create <target repository> <source repositories ...>",
 	Short: "create and push a manifest list",
-	Long:  `Create a manifest list named as target repository from source repositories, then push to registry`,
-	Args:  cobra.MinimumNArgs(2),
+	Long: `Create a manifest list named as target repository from source repositories, then push to registry.
+
+With --template, source repositories are expanded from the template for each of --platforms instead,
+a Go template which can use .OS, .Arch, .Variant and .OSVersion of the platform:
+
+  manifest create --platforms linux/amd64,linux/arm64/v8 --template 'registry/app:1.4-{{.Arch}}{{.Variant}}' registry/app:1.4`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		auth := getAuth(cmd.Flags())
 		targetRepo := args[0]
-		srcRepo := args[1:]
-		digest, err := manifest.PutManifestList(auth, targetRepo, srcRepo...)
+		var srcRepo []manifest.Source
+		if template := getString(cmd.Flags(), "template"); template != "" {
+			if len(args) > 1 {
+				log.Fatalf("source repositories cannot be used with --template")
+			}
+			specs, err := platforms.ParseAll(getStringSlice(cmd.Flags(), "platforms"))
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			if len(specs) == 0 {
+				log.Fatalf("--platforms is required with --template")
+			}
+			if srcRepo, err = manifest.ExpandTemplate(template, specs, getBool(cmd.Flags(), "ignore-missing")); err != nil {
+				log.Fatalf("%s", err)
+			}
+		} else if cmd.Flags().Changed("platforms") {
+			log.Fatalf("--platforms requires --template")
+		} else if len(args) < 2 {
+			log.Fatalf("requires at least one source repository")
+		}
+		for _, img := range args[1:] {
+			srcRepo = append(srcRepo, manifest.Source{Image: img})
+		}
+		plan, err := manifest.PlanManifestList(auth, getCreateOptions(cmd.Flags()), targetRepo, srcRepo)
 		if err != nil {
 			log.Fatalf("%s", err)
 		}
-		fmt.Printf("Target image %s is digest %s\n", targetRepo, digest)
+		pushPlan(cmd.Flags(), plan)
 	},
 }
 
+// addCreateFlags adds the flags of commands creating a manifest list.
+func addCreateFlags(flags *pflag.FlagSet) {
+	flags.Int("jobs", manifest.DefaultJobs, "Number of source images inspected concurrently")
+	flags.Bool("sort", true, "Sort manifest list entries by platform, so that the same images always give the same digest")
+	flags.Bool("dry-run", false, "Resolve the manifest list and print it with the planned registry operations, without pushing")
+	flags.StringSlice("tag", nil, "Additional tag to push the manifest list to, can be repeated")
+	flags.Bool("semver-tags", false, "Also push to the parent version tags of release version tags, e.g. 1.4 and 1 for 1.4.2")
+	flags.Bool("force", false, "Overwrite tags which already point to a different manifest")
+	flags.String("if-digest", "", "Only overwrite the target tag if it currently points to this digest")
+	flags.String("format", "", "Format of the manifest list, docker or oci; by default oci if all images are OCI images, docker otherwise")
+	flags.StringArray("annotation", nil, "Annotation of the OCI image index as key=value, can be repeated")
+	flags.StringArray("manifest-annotation", nil, "Annotation of the entries of a platform as <platform>:key=value, can be repeated")
+	flags.Bool("provenance", false, "Annotate the OCI image index with its source images, their source and revision labels, and the creation time of the newest one, or SOURCE_DATE_EPOCH if set")
+	flags.StringSlice("require-platforms", nil, "Platforms the manifest list must have, no more and no less, like linux/amd64,linux/arm64/v8")
+	flags.String("rules", "", "YAML or JSON file configuring the lint rules the manifest list is checked with before pushing")
+}
+
+func getCreateOptions(flags *pflag.FlagSet) *manifest.CreateOptions {
+	required, err := platforms.ParseAll(getStringSlice(flags, "require-platforms"))
+	if err != nil {
+		log.Fatalf("--require-platforms: %s", err)
+	}
+	annotations, err := manifest.ParseAnnotations(getStringArray(flags, "annotation"))
+	if err != nil {
+		log.Fatalf("--annotation: %s", err)
+	}
+	var manifestAnnotations []manifest.ManifestAnnotation
+	for _, s := range getStringArray(flags, "manifest-annotation") {
+		a, err := manifest.ParseManifestAnnotation(s)
+		if err != nil {
+			log.Fatalf("--manifest-annotation: %s", err)
+		}
+		manifestAnnotations = append(manifestAnnotations, a)
+	}
+	var created time.Time
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		seconds, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			log.Fatalf("invalid SOURCE_DATE_EPOCH %q: %s", epoch, err)
+		}
+		created = time.Unix(seconds, 0)
+	}
+	return &manifest.CreateOptions{
+		Jobs:                getInt(flags, "jobs"),
+		KeepOrder:           !getBool(flags, "sort"),
+		Force:               getBool(flags, "force"),
+		IfDigest:            getString(flags, "if-digest"),
+		Format:              getString(flags, "format"),
+		RequirePlatforms:    required,
+		Annotations:         annotations,
+		ManifestAnnotations: manifestAnnotations,
+		Provenance:          getBool(flags, "provenance"),
+		Created:             created,
+	}
+}
+
+// pushPlan pushes a planned manifest list to its tags, or only prints it in
+// dry-run mode.
+func pushPlan(flags *pflag.FlagSet, plan *manifest.Plan) {
+	if err := plan.AddTags(getStringSlice(flags, "tag")...); err != nil {
+		log.Fatalf("%s", err)
+	}
+	if getBool(flags, "semver-tags") {
+		if err := plan.AddTags(manifest.ExpandSemverTags(plan.Tags())...); err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+
+	lintPlan(flags, plan)
+
+	if getBool(flags, "dry-run") {
+		printPlan(plan)
+		conflicts, err := plan.CheckTags()
+		printTagResults(conflicts)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		return
+	}
+
+	results, err := plan.Push()
+	printTagResults(results)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	fmt.Printf("Target image %s is digest %s\n", plan.Target, plan.Digest)
+}
+
 var annotateCmd = &cobra.Command{
-	Use:   "annotate <target repository>",
-	Short: "annotate a manifest with platform spec",
-	Args:  cobra.MinimumNArgs(1),
+	Use:   "annotate <manifest list>",
+	Short: "annotate an entry of a manifest list with platform spec",
+	Long: `Fetch an existing manifest list, change the platform of one of its entries, then push it back.
+
+  manifest annotate registry/app:1.4 --entry linux/arm --variant v7
+
+The entry is selected by digest or by its current platform. The new platform is checked against the image
+configuration of the entry, unless --no-check is given. The manifest list is pushed to its own tag, only if it
+did not change in the meantime, or to --to-tag.`,
+	Args: cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		// auth := getAuth(cmd.Flags())
-		// targetRepo := args[0]
-		// srcRepo := args[1:]
-		// digest, err := manifest.PutManifestList(auth, targetRepo, srcRepo...)
-		// if err != nil {
-		// 	log.Fatalf("%s", err)
-		// }
-		// fmt.Printf("Target image %s is digest %s\n", targetRepo, digest)
+		flags := cmd.Flags()
+		annotation := &manifest.Annotation{
+			Entry: getString(flags, "entry"),
+			Platform: manifestlist.PlatformSpec{
+				OS:           getString(flags, "os"),
+				Architecture: getString(flags, "arch"),
+				Variant:      getString(flags, "variant"),
+				OSVersion:    getString(flags, "os-version"),
+				OSFeatures:   getStringSlice(flags, "os-features"),
+				Features:     getStringSlice(flags, "features"),
+			},
+			Tag:       getString(flags, "to-tag"),
+			SkipCheck: getBool(flags, "no-check"),
+		}
+		if annotation.Entry == "" {
+			log.Fatalf("--entry is required")
+		}
+
+		auth := getAuth(flags)
+		plan, oldDigest, err := manifest.PlanAnnotate(auth, getCreateOptions(flags), args[0], annotation)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		fmt.Printf("Old digest: %s\n", oldDigest)
+		fmt.Printf("New digest: %s\n", plan.Digest)
+		pushPlan(flags, plan)
 	},
 }
 
 var inspectCmd = &cobra.Command{
 	Use:   "inspect <repository>",
 	Short: "inspect an image repository",
-	Args:  cobra.MinimumNArgs(1),
+	Long: `Inspect an image or a manifest list and its entries.
+
+With --platform, the entry of a manifest list which a host of the platform runs best is picked, falling back like
+container runtimes do, e.g. to arm/v7 for linux/arm64, and inspected as if it had been referenced directly. Its
+configuration and layer descriptors are shown too, with the image configuration.`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		imageName := args[0]
 		namedRef, err := reference.ParseNamed(imageName)
@@ -87,6 +296,21 @@ var inspectCmd = &cobra.Command{
 		}
 
 		repo, id := manifest.Parse(namedRef)
+		if s := getString(cmd.Flags(), "platform"); s != "" {
+			platform, err := platforms.Parse(s)
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			img, err := manifest.InspectPlatform(r, repo, id, platform)
+			if err != nil {
+				log.Fatalf("%s: %s", imageName, err)
+			}
+			fmt.Printf("Name:   %s@%s\n", namedRef.Name(), img.Digest)
+			printImage(1, img)
+			printImageDetails(img)
+			return
+		}
+
 		imgs, err := manifest.Inspect(r, repo, id)
 		if err != nil {
 			log.Fatalf("%s", err)
@@ -94,30 +318,148 @@ var inspectCmd = &cobra.Command{
 
 		idx := 0
 		for _, img := range imgs {
-			if img.MediaType == manifestlist.MediaTypeManifestList {
+			if manifest.IsManifestList(img.MediaType) {
 				fmt.Printf("Name:   %s\n", imageName)
 				fmt.Printf("Manifest Type: %s\n", img.MediaType)
 				fmt.Printf("Digest: %s\n", img.Digest)
-				fmt.Printf(" * Contains %d manifest references:\n", len(img.Manifest.(*manifestlist.DeserializedManifestList).Manifests))
+				for _, key := range sortedKeys(img.Annotations) {
+					fmt.Printf("Annotation: %s=%s\n", key, img.Annotations[key])
+				}
+				fmt.Printf(" * Contains %d manifest references:\n", len(img.Manifest.References()))
 				idx = 0
 				continue
 			}
 			idx++
-			fmt.Printf("%d    Manifest Type: %s\n", idx, img.MediaType)
-			fmt.Printf("%d           Digest: %s\n", idx, img.Digest)
-			fmt.Printf("%d  Manifest Length: %d\n", idx, img.Size)
-			fmt.Printf("%d         Platform:\n", idx)
-			fmt.Printf("%d           -      OS: %s\n", idx, img.Platform.OS)
-			fmt.Printf("%d           -    Arch: %s\n", idx, img.Platform.Architecture)
-			fmt.Printf("%d           - OS Vers: %s\n", idx, img.Platform.OSVersion)
-			fmt.Printf("%d           - OS Feat: %s\n", idx, img.Platform.OSFeatures)
-			fmt.Printf("%d           - Variant: %s\n", idx, img.Platform.Variant)
-			fmt.Printf("%d           - Feature: %s\n", idx, strings.Join(img.Platform.Features, ","))
-			fmt.Println()
+			printImage(idx, img)
 		}
 	},
 }
 
+// printImage prints an image, as the idx-th entry of a manifest list.
+func printImage(idx int, img manifest.ImageInspect) {
+	fmt.Printf("%d    Manifest Type: %s\n", idx, img.MediaType)
+	fmt.Printf("%d           Digest: %s\n", idx, img.Digest)
+	fmt.Printf("%d  Manifest Length: %d\n", idx, img.Size)
+	fmt.Printf("%d         Platform:\n", idx)
+	fmt.Printf("%d           -      OS: %s\n", idx, img.Platform.OS)
+	fmt.Printf("%d           -    Arch: %s\n", idx, img.Platform.Architecture)
+	fmt.Printf("%d           - OS Vers: %s\n", idx, img.Platform.OSVersion)
+	fmt.Printf("%d           - OS Feat: %s\n", idx, img.Platform.OSFeatures)
+	fmt.Printf("%d           - Variant: %s\n", idx, img.Platform.Variant)
+	fmt.Printf("%d           - Feature: %s\n", idx, strings.Join(img.Platform.Features, ","))
+	for _, key := range sortedKeys(img.Annotations) {
+		fmt.Printf("%d       Annotation: %s=%s\n", idx, key, img.Annotations[key])
+	}
+	for _, layer := range manifest.ForeignLayers(img.Manifest) {
+		fmt.Printf("%d    Foreign Layer: %s\n", idx, layer.Digest)
+		for _, url := range layer.URLs {
+			fmt.Printf("%d           -     URL: %s\n", idx, url)
+		}
+	}
+	fmt.Println()
+}
+
+// printImageDetails prints the configuration and layer descriptors of an
+// image, then its configuration.
+func printImageDetails(img manifest.ImageInspect) {
+	refs := img.Manifest.References()
+	if len(refs) > 0 {
+		fmt.Printf("Config: %s %s (%d bytes)\n", refs[0].MediaType, refs[0].Digest, refs[0].Size)
+		fmt.Printf("Layers:\n")
+		for _, layer := range refs[1:] {
+			fmt.Printf("  - %s %s (%d bytes)\n", layer.MediaType, layer.Digest, layer.Size)
+		}
+	}
+	if len(img.Config) > 0 {
+		var config bytes.Buffer
+		if err := json.Indent(&config, img.Config, "", "  "); err != nil {
+			log.Fatalf("invalid image configuration: %s", err)
+		}
+		fmt.Printf("Image Configuration:\n%s\n", config.String())
+	}
+}
+
+// lintPlan checks the manifest list of a plan with the lint rules, and
+// refuses to push it if any error is found. Entries with the same platform
+// are only warned about, unless the rules file sets the severity of
+// duplicate-platform.
+func lintPlan(flags *pflag.FlagSet, plan *manifest.Plan) {
+	cfg, err := manifest.LoadLintConfig(getString(flags, "rules"))
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	if _, ok := cfg.Rules["duplicate-platform"]; !ok {
+		if cfg.Rules == nil {
+			cfg.Rules = make(map[string]manifest.Severity)
+		}
+		cfg.Rules["duplicate-platform"] = manifest.SeverityWarning
+	}
+	findings, err := plan.Lint(cfg)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	for _, finding := range findings {
+		if finding.Severity == manifest.SeverityError {
+			log.Errorf("%s: %s", finding.Rule, finding.Message)
+		} else {
+			log.Warnf("%s: %s", finding.Rule, finding.Message)
+		}
+	}
+	if manifest.HasErrors(findings) {
+		log.Fatalf("Refusing to push %s with lint errors, the severity of rules can be changed with --rules", plan.Target)
+	}
+}
+
+// printTagResults prints the result of pushing to each tag.
+func printTagResults(results []manifest.TagResult) {
+	for _, result := range results {
+		if result.Err != nil {
+			fmt.Printf("Tag %s failed: %s\n", result.Tag, result.Err)
+			continue
+		}
+		if result.Unchanged {
+			fmt.Printf("Tag %s is unchanged, digest %s\n", result.Tag, result.Digest)
+			continue
+		}
+		fmt.Printf("Tag %s is digest %s\n", result.Tag, result.Digest)
+	}
+}
+
+// printPlan prints a manifest list and the registry operations needed to
+// push it.
+func printPlan(plan *manifest.Plan) {
+	_, payload, err := plan.List.Payload()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	fmt.Printf("%s\n", payload)
+	fmt.Printf("Digest: %s\n", plan.Digest)
+	fmt.Println("Planned operations:")
+	for i, op := range plan.Operations() {
+		fmt.Printf("%d  %s\n", i+1, op)
+	}
+}
+
+// writeReport writes a report as JSON to a file, logging any failure.
+func writeReport(path string, report interface{}) {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(path, append(content, '\n'), 0644)
+	}
+	if err != nil {
+		log.Errorf("Failed to write report %s: %s", path, err)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func getAuth(flags *pflag.FlagSet) *manifest.AuthInfo {
 	return &manifest.AuthInfo{
 		Username:  getString(flags, "username"),
@@ -141,3 +483,35 @@ func getBool(flags *pflag.FlagSet, flag string) bool {
 	}
 	return val
 }
+
+func getDuration(flags *pflag.FlagSet, flag string) time.Duration {
+	val, err := flags.GetDuration(flag)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	return val
+}
+
+func getInt(flags *pflag.FlagSet, flag string) int {
+	val, err := flags.GetInt(flag)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	return val
+}
+
+func getStringSlice(flags *pflag.FlagSet, flag string) []string {
+	val, err := flags.GetStringSlice(flag)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	return val
+}
+
+func getStringArray(flags *pflag.FlagSet, flag string) []string {
+	val, err := flags.GetStringArray(flag)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+	return val
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit <image|repository>",
+	Short: "check the integrity of the blobs of an image, a manifest list or a repository",
+	Long: `Check every configuration and layer blob referenced by an image, a manifest list, or every tag of a repository
+if no tag is given. By default only the existence and size of blobs are checked; with --deep every blob is
+downloaded and re-hashed. With --state, results are recorded as they are known, and blobs already found
+healthy are skipped when the audit is run again, so that an interrupted audit resumes where it stopped:
+
+  manifest audit registry/app --deep --jobs 8 --state audit.state`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := &manifest.AuditOptions{
+			Deep:      getBool(cmd.Flags(), "deep"),
+			Jobs:      getInt(cmd.Flags(), "jobs"),
+			StateFile: getString(cmd.Flags(), "state"),
+		}
+
+		auth := getAuth(cmd.Flags())
+		report, err := manifest.Audit(auth, args[0], opts)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		if getBool(cmd.Flags(), "json") {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				log.Fatalf("%s", err)
+			}
+		} else {
+			printAuditReport(report)
+		}
+		if !report.OK() {
+			log.Fatalf("%s has unhealthy blobs or manifests", args[0])
+		}
+	},
+}
+
+func printAuditReport(report *manifest.AuditReport) {
+	fmt.Printf("%s: %d manifests, %d blobs: %d healthy, %d corrupt, %d missing, %d failed\n",
+		report.Image, report.Manifests, len(report.Blobs), report.Count(manifest.BlobHealthy),
+		report.Count(manifest.BlobCorrupt), report.Count(manifest.BlobMissing), report.Count(manifest.BlobFailed))
+	for _, problem := range report.Problems {
+		fmt.Printf("  manifest %s\n", problem)
+	}
+	for _, blob := range report.Blobs {
+		if blob.Status == manifest.BlobHealthy {
+			continue
+		}
+		fmt.Printf("  %-7s %s", blob.Status, blob.Digest)
+		if blob.Detail != "" {
+			fmt.Printf(": %s", blob.Detail)
+		}
+		fmt.Printf("\n          referenced by %s\n", strings.Join(blob.References, ", "))
+	}
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert <manifest list|image>",
+	Short: "convert a manifest list or an image between the docker and OCI formats",
+	Long: `Convert a manifest list and every image manifest it references between docker manifest lists and
+OCI image indexes, including the media types of configurations and layers. The converted image manifests
+are pushed by their new digests before the converted manifest list. Without --dest, the tag is converted in
+place, unless it changed meanwhile:
+
+  manifest convert registry/app:1.4 --to oci
+  manifest convert registry/app:1.4 --to docker --dest registry/app:1.4-docker`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := &manifest.ConvertOptions{
+			Format: getString(cmd.Flags(), "to"),
+			Force:  getBool(cmd.Flags(), "force"),
+		}
+		if opts.Format == "" {
+			log.Fatalf("--to is required")
+		}
+
+		auth := getAuth(cmd.Flags())
+		results, err := manifest.Convert(auth, args[0], getString(cmd.Flags(), "dest"), opts)
+		printTagResults(results)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	},
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"github.com/sakeven/manifest/pkg/manifest"
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var copyCmd = &cobra.Command{
+	Use:   "copy <source> <destination>",
+	Short: "copy an image or a manifest list to another repository or registry",
+	Long: `Copy an image or a manifest list, with all its manifests, configurations and layers, preserving their digests.
+Blobs are mounted between repositories of the same registry, and streamed between registries. The tag of the
+source is used if the destination has none:
+
+  manifest copy registry/app:1.4 mirror.example.com/app
+  manifest copy registry/app:1.4 registry/app-arm:1.4 --platform linux/arm64,linux/arm/v7
+  manifest copy registry/app mirror.example.com/app --all-tags`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		selectors, err := platforms.ParseAll(getStringSlice(cmd.Flags(), "platform"))
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		opts := &manifest.CopyOptions{
+			Platforms: selectors,
+			AllTags:   getBool(cmd.Flags(), "all-tags"),
+			Force:     getBool(cmd.Flags(), "force"),
+		}
+
+		auth := getAuth(cmd.Flags())
+		results, err := manifest.Copy(auth, args[0], args[1], opts)
+		printTagResults(results)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	},
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <image a> <image b>",
+	Short: "compare two images or manifest lists",
+	Long: `Compare two images or manifest lists without pulling their layers. Platforms added to or removed from a
+manifest list are shown, and the images of the other platforms are compared by layers, size, and env, labels,
+entrypoint and user of their configuration:
+
+  manifest diff registry/app:1.4.1 registry/app:1.4.2`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		auth := getAuth(cmd.Flags())
+		diff, err := manifest.DiffImages(auth, args[0], args[1])
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		if getBool(cmd.Flags(), "json") {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(diff); err != nil {
+				log.Fatalf("%s", err)
+			}
+			return
+		}
+		printDiff(diff)
+	},
+}
+
+func printDiff(diff *manifest.Diff) {
+	fmt.Printf("--- %s %s\n", diff.A, diff.DigestA)
+	fmt.Printf("+++ %s %s\n", diff.B, diff.DigestB)
+	if diff.Empty() {
+		fmt.Println("No differences")
+		return
+	}
+	for _, platform := range diff.Removed {
+		fmt.Printf("- %s\n", platform)
+	}
+	for _, platform := range diff.Added {
+		fmt.Printf("+ %s\n", platform)
+	}
+	for _, d := range diff.Changed {
+		fmt.Printf("~ %s %s -> %s\n", d.Platform, d.DigestA, d.DigestB)
+		for _, layer := range d.RemovedLayers {
+			fmt.Printf("    - layer %s\n", layer)
+		}
+		for _, layer := range d.AddedLayers {
+			fmt.Printf("    + layer %s\n", layer)
+		}
+		for _, field := range d.Config {
+			fmt.Printf("    ~ %s: %q -> %q\n", field.Field, field.A, field.B)
+		}
+		if d.SizeA != d.SizeB {
+			fmt.Printf("    ~ size: %d -> %d (%+d bytes)\n", d.SizeA, d.SizeB, d.SizeB-d.SizeA)
+		}
+	}
+	for _, platform := range diff.Unchanged {
+		fmt.Printf("  %s\n", platform)
+	}
+}
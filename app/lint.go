@@ -0,0 +1,82 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint <manifest list|spec file>",
+	Short: "check a manifest list or a spec file for common mistakes",
+	Long: `Check a manifest list of a registry, or the one a spec file describes, with lint rules. The same rules are
+checked by the commands pushing manifest lists, which refuse to push when a rule of the error severity fails.
+The severity of rules can be changed, and required annotations set, with a rules file:
+
+  rules:
+    arm-variant: error
+    mixed-media-types: "off"
+  requiredAnnotations:
+    - org.opencontainers.image.source
+
+Exits non-zero if a rule of the error severity fails.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if getBool(cmd.Flags(), "list-rules") {
+			for _, rule := range manifest.Rules {
+				fmt.Printf("%-22s %-8s %s\n", rule.ID, rule.Severity, rule.Description)
+			}
+			return
+		}
+		if len(args) == 0 {
+			log.Fatalf("requires a manifest list or a spec file")
+		}
+
+		cfg, err := manifest.LoadLintConfig(getString(cmd.Flags(), "rules"))
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		auth := getAuth(cmd.Flags())
+		var findings []manifest.Finding
+		if info, statErr := os.Stat(args[0]); statErr == nil && info.Mode().IsRegular() {
+			spec, err := manifest.LoadSpec(args[0])
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			plan, err := manifest.PlanSpec(auth, &manifest.CreateOptions{}, spec)
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+			findings, err = plan.Lint(cfg)
+		} else {
+			findings, err = manifest.LintImage(auth, args[0], cfg)
+		}
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		if getBool(cmd.Flags(), "json") {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(findings); err != nil {
+				log.Fatalf("%s", err)
+			}
+		} else {
+			for _, finding := range findings {
+				fmt.Printf("%-7s %s: %s\n", finding.Severity, finding.Rule, finding.Message)
+			}
+			if len(findings) == 0 {
+				fmt.Printf("%s: no problems found\n", args[0])
+			}
+		}
+		if manifest.HasErrors(findings) {
+			log.Fatalf("%s has lint errors", args[0])
+		}
+	},
+}
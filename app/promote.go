@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/manifest"
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote <source> <destination>",
+	Short: "copy an image or a manifest list by digest once policy checks pass",
+	Long: `Copy an image or a manifest list by digest, like from a staging to a production repository, only if every
+policy check passes. The destination tag must not point to another manifest unless --force is given. The
+result of every check is printed, and can be written to a JSON report or recorded as annotations on an
+OCI image index:
+
+  manifest promote staging.example.com/app:1.4 registry.example.com/app \
+    --expect-digest sha256:... --require-platforms linux/amd64,linux/arm64 \
+    --require-labels org.opencontainers.image.source --report promote.json`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		required, err := platforms.ParseAll(getStringSlice(cmd.Flags(), "require-platforms"))
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		opts := &manifest.PromoteOptions{
+			ExpectDigest:     getString(cmd.Flags(), "expect-digest"),
+			RequirePlatforms: required,
+			RequireLabels:    getStringSlice(cmd.Flags(), "require-labels"),
+			Annotate:         getBool(cmd.Flags(), "annotate"),
+			Force:            getBool(cmd.Flags(), "force"),
+		}
+
+		auth := getAuth(cmd.Flags())
+		report, err := manifest.Promote(auth, args[0], args[1], opts)
+		if report != nil {
+			printPromoteReport(report)
+			if path := getString(cmd.Flags(), "report"); path != "" {
+				writeReport(path, report)
+			}
+		}
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	},
+}
+
+func printPromoteReport(report *manifest.PromoteReport) {
+	for _, check := range report.Checks {
+		status := "passed"
+		if !check.Passed {
+			status = "failed: " + check.Detail
+		}
+		fmt.Printf("Check %s %s\n", check.Name, status)
+	}
+	if report.Promoted {
+		fmt.Printf("%s is promoted to %s, digest %s\n", report.Source, report.Destination, report.Digest)
+	}
+}
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var pushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "push a manifest list described by a file",
+}
+
+var fromSpecCmd = &cobra.Command{
+	Use:   "from-spec <spec file>",
+	Short: "create and push a manifest list from a YAML or JSON spec",
+	Long: `Create a manifest list from a YAML or JSON spec, then push it to the image and tags of the spec.
+
+A spec looks like:
+
+  image: registry.example.com/app:1.4.2
+  tags: ["1.4", "latest"]
+  requirePlatforms: [linux/amd64, linux/arm64/v8]
+  manifests:
+    - image: registry.example.com/app:1.4.2-amd64
+    - image: registry.example.com/app:1.4.2-arm64
+      platform:
+        variant: v8`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		spec, err := manifest.LoadSpec(args[0])
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		auth := getAuth(cmd.Flags())
+		plan, err := manifest.PlanSpec(auth, getCreateOptions(cmd.Flags()), spec)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		pushPlan(cmd.Flags(), plan)
+	},
+}
+
+var validateCmd = &cobra.Command{
+	Use:   "validate <spec file ...>",
+	Short: "validate YAML or JSON spec files",
+	Run: func(cmd *cobra.Command, args []string) {
+		if getBool(cmd.Flags(), "schema") {
+			fmt.Print(manifest.SpecSchema)
+			return
+		}
+		if len(args) == 0 {
+			log.Fatalf("requires at least 1 spec file")
+		}
+
+		failed := false
+		for _, path := range args {
+			spec, err := manifest.LoadSpec(path)
+			if err == nil {
+				err = spec.Validate()
+			}
+			if err != nil {
+				fmt.Printf("%s: %s\n", path, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s: valid\n", path)
+		}
+		if failed {
+			log.Fatalf("invalid spec files")
+		}
+	},
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var splitCmd = &cobra.Command{
+	Use:   "split <manifest list>",
+	Short: "push each entry of a manifest list under its own tag",
+	Long: `Push the manifest of each entry of a manifest list under its own tag, for clients which do not support
+manifest lists. The tag of each entry is given by a Go template, which can use .Tag, .OS, .Arch, .Variant and
+.OSVersion:
+
+  manifest split registry/app:1.4 --tag-template '{{.Tag}}-{{.OS}}-{{.Arch}}{{.Variant}}' --repo registry/app-legacy`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := &manifest.SplitOptions{
+			TagTemplate: getString(cmd.Flags(), "tag-template"),
+			Repository:  getString(cmd.Flags(), "repo"),
+			Force:       getBool(cmd.Flags(), "force"),
+		}
+
+		auth := getAuth(cmd.Flags())
+		results, err := manifest.Split(auth, args[0], opts)
+		printTagResults(results)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	},
+}
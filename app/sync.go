@@ -0,0 +1,83 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <config file>",
+	Short: "mirror repositories described by a YAML or JSON config",
+	Long: `Copy the tags of repositories which are missing from, or point to another digest in, their mirror. The tags
+are listed on both sides, and only missing or changed ones are copied. A config looks like:
+
+  repositories:
+    - source: registry.example.com/app
+      destination: mirror.example.com/app
+      tags: '1\.[0-9]+\.[0-9]+'
+      maxTags: 10
+      platforms: [linux/amd64, linux/arm64]
+
+The tags regular expression must match the whole tag, and maxTags keeps the highest tags in version order.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := manifest.LoadSyncConfig(args[0])
+		if err == nil {
+			err = cfg.Validate()
+		}
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		auth := getAuth(cmd.Flags())
+		interval := getDuration(cmd.Flags(), "interval")
+		for {
+			report, err := manifest.Sync(auth, cfg)
+			if report != nil {
+				printSyncReport(report)
+				if path := getString(cmd.Flags(), "report"); path != "" {
+					writeReport(path, report)
+				}
+			}
+			if interval == 0 {
+				if err != nil {
+					log.Fatalf("%s", err)
+				}
+				return
+			}
+			if err != nil {
+				log.Errorf("%s", err)
+			}
+			log.Infof("Next sync in %s", interval)
+			time.Sleep(interval)
+		}
+	},
+}
+
+func printSyncReport(report *manifest.SyncReport) {
+	for _, result := range report.Repositories {
+		if result.Error != "" {
+			fmt.Printf("%s -> %s failed: %s\n", result.Source, result.Destination, result.Error)
+			continue
+		}
+		fmt.Printf("%s -> %s: %d copied, %d up to date, %d failed\n",
+			result.Source, result.Destination, len(result.Copied), len(result.UpToDate), len(result.Failed))
+		for _, tag := range result.Copied {
+			fmt.Printf("  copied %s\n", tag)
+		}
+		var failed []string
+		for tag := range result.Failed {
+			failed = append(failed, tag)
+		}
+		sort.Strings(failed)
+		for _, tag := range failed {
+			fmt.Printf("  failed %s: %s\n", tag, result.Failed[tag])
+		}
+	}
+}
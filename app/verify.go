@@ -0,0 +1,96 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sakeven/manifest/pkg/manifest"
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <manifest list>",
+	Short: "verify that a manifest list matches the images it references",
+	Long: `Fetch every manifest a manifest list references, and check its size, digest and media type against the entry,
+and the os, architecture, variant and os.version of its image configuration against the platform of the entry.
+With --layers, every layer is also checked to exist. Exits non-zero if any entry has a problem.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := &manifest.VerifyOptions{
+			Layers: getBool(cmd.Flags(), "layers"),
+			Jobs:   getInt(cmd.Flags(), "jobs"),
+		}
+
+		auth := getAuth(cmd.Flags())
+		report, err := manifest.Verify(auth, args[0], opts)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		if getBool(cmd.Flags(), "json") {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				log.Fatalf("%s", err)
+			}
+		} else {
+			printVerifyReport(report)
+		}
+		if !report.OK() {
+			log.Fatalf("%s does not match the images it references", args[0])
+		}
+	},
+}
+
+func printVerifyReport(report *manifest.VerifyReport) {
+	fmt.Printf("%s %s\n", report.Image, report.Digest)
+	for _, entry := range report.Entries {
+		if len(entry.Problems) == 0 {
+			fmt.Printf("  ok     %s %s\n", entry.Platform, entry.Digest)
+			continue
+		}
+		fmt.Printf("  failed %s %s\n", entry.Platform, entry.Digest)
+		for _, problem := range entry.Problems {
+			fmt.Printf("         - %s\n", problem)
+		}
+	}
+}
+
+var checkPlatformsCmd = &cobra.Command{
+	Use:   "check-platforms <manifest list>",
+	Short: "check that a manifest list has exactly the required platforms",
+	Long: `Check that a manifest list has exactly the platforms of --platforms, no more and no less, to gate releases
+on existing tags:
+
+  manifest check-platforms registry/app:1.4 --platforms linux/amd64,linux/arm64/v8,linux/ppc64le
+
+A required platform without variant matches entries of any variant. Exits non-zero if a platform is missing or
+unexpected.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		required, err := platforms.ParseAll(getStringSlice(cmd.Flags(), "platforms"))
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		if len(required) == 0 {
+			log.Fatalf("--platforms is required")
+		}
+
+		auth := getAuth(cmd.Flags())
+		actual, err := manifest.CheckImagePlatforms(auth, args[0], required)
+		if actual == nil && err != nil {
+			log.Fatalf("%s", err)
+		}
+		for _, platform := range actual {
+			fmt.Printf("  %s\n", platforms.Format(platform))
+		}
+		if err != nil {
+			log.Fatalf("%s: %s", args[0], err)
+		}
+		fmt.Printf("%s has the required platforms\n", args[0])
+	},
+}
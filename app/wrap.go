@@ -0,0 +1,40 @@
+package app
+
+import (
+	"github.com/sakeven/manifest/pkg/manifest"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/spf13/cobra"
+)
+
+var wrapCmd = &cobra.Command{
+	Use:   "wrap <image> [target]",
+	Short: "wrap a single image in a manifest list with one entry",
+	Long: `Push a manifest list with a single entry for an image, to target or in place of the image.
+
+The platform of the entry is read from the image configuration, the variant falling back to the
+org.opencontainers.image.variant and variant labels, and can be overridden by flags. An OCI image index is
+pushed for OCI images, and a docker manifest list otherwise, unless --format is given.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		var target string
+		if len(args) > 1 {
+			target = args[1]
+		}
+		platform := &manifestlist.PlatformSpec{
+			OS:           getString(flags, "os"),
+			Architecture: getString(flags, "arch"),
+			Variant:      getString(flags, "variant"),
+			OSVersion:    getString(flags, "os-version"),
+		}
+
+		auth := getAuth(flags)
+		plan, err := manifest.PlanWrap(auth, getCreateOptions(flags), args[0], target, platform)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+		pushPlan(flags, plan)
+	},
+}
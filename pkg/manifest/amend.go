@@ -0,0 +1,200 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+// Amendment describes changes to the entries of an existing manifest list.
+type Amendment struct {
+	// Add are source images added to the list.
+	Add []Source
+
+	// Remove selects the entries removed from the list, either by digest or
+	// by platform written as os/arch[/variant].
+	Remove []string
+
+	// Replace replaces entries selected by platform with other images.
+	Replace []Replacement
+}
+
+// Replacement replaces the entry of a platform with another image, which
+// keeps the platform of the replaced entry.
+type Replacement struct {
+	Platform manifestlist.PlatformSpec
+	Image    string
+}
+
+// ParseReplacement parses a replacement written as <platform>=<image>.
+func ParseReplacement(s string) (Replacement, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Replacement{}, fmt.Errorf("invalid replacement %q, expected <platform>=<image>", s)
+	}
+	p, err := platforms.Parse(parts[0])
+	if err != nil {
+		return Replacement{}, err
+	}
+	return Replacement{Platform: p, Image: parts[1]}, nil
+}
+
+// PlanAmend fetches an existing manifest list, applies an amendment to its
+// entries and plans pushing the result back to the same tag. The digest of
+// the existing list is returned too.
+//
+// Unless forced or given another expected digest, the plan only overwrites
+// the tag if it still points to the fetched list when pushing.
+func PlanAmend(a *AuthInfo, opts *CreateOptions, listImage string, amendment *Amendment) (*Plan, digest.Digest, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, "", err
+	}
+
+	ref, err := reference.ParseNamed(listImage)
+	if err != nil {
+		return nil, "", err
+	}
+	plan := newPlan(a, opts, ref)
+
+	manifests, oldDigest, err := plan.fetchManifestList(ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, selector := range amendment.Remove {
+		var removed []manifestlist.ManifestDescriptor
+		manifests, removed, err = removeEntries(manifests, selector)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(removed) == 0 {
+			return nil, "", fmt.Errorf("no entry of %s matches %s", listImage, selector)
+		}
+	}
+
+	srcs := append([]Source(nil), amendment.Add...)
+	for _, replacement := range amendment.Replace {
+		selector := platforms.Format(replacement.Platform)
+		var removed []manifestlist.ManifestDescriptor
+		manifests, removed, err = removeEntries(manifests, selector)
+		if err != nil {
+			return nil, "", err
+		}
+		if len(removed) != 1 {
+			return nil, "", fmt.Errorf("%d entries of %s match %s, expected exactly one to replace", len(removed), listImage, selector)
+		}
+		platform := removed[0].Platform
+		srcs = append(srcs, Source{Image: replacement.Image, Platform: &platform})
+	}
+
+	if len(srcs) > 0 {
+		log.Info("Retrieving digests of images...")
+		resolved, err := resolveSources(plan.clients, ref, srcs, opts.Jobs)
+		if err != nil {
+			return nil, "", err
+		}
+		manifests = append(manifests, plan.addSources(resolved)...)
+	}
+	if len(manifests) == 0 {
+		return nil, "", fmt.Errorf("amended manifest list %s has no entries left", listImage)
+	}
+
+	// compare-then-set, so that concurrent changes are not lost
+	_, tag := Parse(ref)
+	plan.expectDigest(tag, oldDigest)
+	if err := plan.setManifests(manifests); err != nil {
+		return nil, "", err
+	}
+	return plan, oldDigest, nil
+}
+
+// fetchManifestList fetches a docker manifest list or an OCI image index, and
+// returns a copy of its entries with its digest. The plan keeps the format of
+// the list unless the options set one, and the annotations of an index and of
+// its entries, so that rebuilding the list does not lose them.
+func (p *Plan) fetchManifestList(ref reference.Named) ([]manifestlist.ManifestDescriptor, digest.Digest, error) {
+	r, err := p.clients.get(ref.Hostname())
+	if err != nil {
+		return nil, "", err
+	}
+
+	repo, tagOrDigest := Parse(ref)
+	m, err := r.FetchManifest(repo, tagOrDigest)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch of %s failed: %s", ref, err)
+	}
+	entries, err := listEntries(m)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s is not a manifest list", ref)
+	}
+	_, payload, err := m.Payload()
+	if err != nil {
+		return nil, "", err
+	}
+
+	p.format = FormatDocker
+	if index, ok := m.(*ocischema.DeserializedIndex); ok {
+		p.format = FormatOCI
+		p.annotations = mergeAnnotations(index.Annotations)
+		for _, desc := range index.Manifests {
+			if len(desc.Annotations) == 0 {
+				continue
+			}
+			if p.entryAnnotations == nil {
+				p.entryAnnotations = make(map[digest.Digest]map[string]string)
+			}
+			p.entryAnnotations[desc.Digest] = mergeAnnotations(desc.Annotations)
+		}
+	}
+
+	manifests := make([]manifestlist.ManifestDescriptor, len(entries))
+	copy(manifests, entries)
+	return manifests, digest.FromBytes(payload), nil
+}
+
+// removeEntries removes the entries matching a selector, which is either a
+// digest or a platform. It returns the remaining and the removed entries.
+func removeEntries(manifests []manifestlist.ManifestDescriptor, selector string) ([]manifestlist.ManifestDescriptor, []manifestlist.ManifestDescriptor, error) {
+	match, err := entrySelector(selector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var kept, removed []manifestlist.ManifestDescriptor
+	for _, m := range manifests {
+		if match(m) {
+			removed = append(removed, m)
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept, removed, nil
+}
+
+// entrySelector returns a function matching the manifest list entries
+// selected by a digest or a platform.
+func entrySelector(selector string) (func(manifestlist.ManifestDescriptor) bool, error) {
+	if dgst, err := digest.Parse(selector); err == nil {
+		return func(m manifestlist.ManifestDescriptor) bool {
+			return m.Digest == dgst
+		}, nil
+	}
+
+	p, err := platforms.Parse(selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %q, expected a digest or a platform: %s", selector, err)
+	}
+	return func(m manifestlist.ManifestDescriptor) bool {
+		return platforms.Match(p, m.Platform)
+	}, nil
+}
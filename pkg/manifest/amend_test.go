@@ -0,0 +1,91 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+func TestPlanAmendExtraTags(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	old := putFakeList(r, "app", "1.4", amd64, arm64)
+	other := putFakeList(r, "app", "other", arm64)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	plan, oldDigest, err := PlanAmend(auth, nil, r.Host()+"/app:1.4", &Amendment{Remove: []string{"linux/arm64"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldDigest != old {
+		t.Errorf("PlanAmend returned old digest %s, want %s", oldDigest, old)
+	}
+	if err := plan.AddTags("1", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := plan.Push()
+	if err == nil {
+		t.Error("pushing over tag other succeeded without --force")
+	}
+	for _, result := range results {
+		switch result.Tag {
+		case "1.4", "1":
+			if result.Err != nil || r.digest("app", result.Tag) != plan.Digest {
+				t.Errorf("tag %s: %v, want it to point to %s", result.Tag, result.Err, plan.Digest)
+			}
+		case "other":
+			if result.Err == nil || !strings.Contains(result.Err.Error(), "use --force") || r.digest("app", "other") != other {
+				t.Errorf("tag other: %v, want it refused and kept", result.Err)
+			}
+		}
+	}
+
+	// the amended tag is only overwritten if it did not change meanwhile
+	putFakeList(r, "app", "2.0", amd64, arm64)
+	plan, _, err = PlanAmend(auth, nil, r.Host()+"/app:2.0", &Amendment{Remove: []string{"linux/arm64"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	putFakeList(r, "app", "2.0", arm64)
+	if _, err := plan.Push(); err == nil || !strings.Contains(err.Error(), "expected") {
+		t.Errorf("pushing over a changed tag gave %v, want it refused", err)
+	}
+}
+
+func TestPlanAmendIndex(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	descs := toOCIDescriptors([]manifestlist.ManifestDescriptor{
+		{Descriptor: putFakeImage(r, "app", "", amd64), Platform: amd64},
+		{Descriptor: putFakeImage(r, "app", "", arm64), Platform: arm64},
+	})
+	descs[0].Annotations = map[string]string{"entry": "amd64"}
+	index, err := ocischema.FromDescriptors(descs, map[string]string{"index": "1.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.put("app", "1.4", index)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	plan, _, err := PlanAmend(auth, nil, r.Host()+"/app:1.4", &Amendment{Remove: []string{"linux/arm64"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	amended, ok := plan.List.(*ocischema.DeserializedIndex)
+	if !ok {
+		t.Fatalf("amended an OCI image index into a %T", plan.List)
+	}
+	if amended.Annotations["index"] != "1.4" || len(amended.Manifests) != 1 || amended.Manifests[0].Annotations["entry"] != "amd64" {
+		t.Errorf("annotations were not kept: %v, %v", amended.Annotations, amended.Manifests)
+	}
+}
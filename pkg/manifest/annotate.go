@@ -0,0 +1,116 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/reference"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+// Annotation changes the platform of one entry of a manifest list.
+type Annotation struct {
+	// Entry selects the annotated entry, either by digest or by platform
+	// written as os/arch[/variant].
+	Entry string
+
+	// Platform holds the platform fields to set. Only the fields that are set
+	// are changed.
+	Platform manifestlist.PlatformSpec
+
+	// Tag is the tag the annotated manifest list is pushed to. The tag of the
+	// manifest list is used if it is empty.
+	Tag string
+
+	// SkipCheck skips checking the annotated platform against the image
+	// configuration of the entry.
+	SkipCheck bool
+}
+
+// PlanAnnotate fetches an existing manifest list, changes the platform of one
+// of its entries, and plans pushing the result. The digest of the existing
+// list is returned too.
+//
+// When pushing back to the same tag, unless forced or given another expected
+// digest, the plan only overwrites the tag if it still points to the fetched
+// list.
+func PlanAnnotate(a *AuthInfo, opts *CreateOptions, listImage string, annotation *Annotation) (*Plan, digest.Digest, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, "", err
+	}
+
+	ref, err := reference.ParseNamed(listImage)
+	if err != nil {
+		return nil, "", err
+	}
+
+	target := ref
+	if annotation.Tag != "" {
+		if target, err = reference.WithTag(reference.TrimNamed(ref), annotation.Tag); err != nil {
+			return nil, "", err
+		}
+	}
+	plan := newPlan(a, opts, target)
+
+	manifests, oldDigest, err := plan.fetchManifestList(ref)
+	if err != nil {
+		return nil, "", err
+	}
+
+	match, err := entrySelector(annotation.Entry)
+	if err != nil {
+		return nil, "", err
+	}
+	var selected []int
+	for i, m := range manifests {
+		if match(m) {
+			selected = append(selected, i)
+		}
+	}
+	if len(selected) != 1 {
+		return nil, "", fmt.Errorf("%d entries of %s match %s, expected exactly one to annotate", len(selected), listImage, annotation.Entry)
+	}
+
+	entry := &manifests[selected[0]]
+	entry.Platform = overridePlatform(entry.Platform, annotation.Platform)
+
+	if !annotation.SkipCheck {
+		if err := checkEntry(plan, ref, *entry); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// compare-then-set, so that concurrent changes are not lost
+	_, oldTag := Parse(ref)
+	plan.expectDigest(oldTag, oldDigest)
+	if err := plan.setManifests(manifests); err != nil {
+		return nil, "", err
+	}
+	return plan, oldDigest, nil
+}
+
+// checkEntry checks the platform of a manifest list entry against the image
+// configuration of the manifest it references.
+func checkEntry(plan *Plan, ref reference.Named, entry manifestlist.ManifestDescriptor) error {
+	r, err := plan.clients.get(ref.Hostname())
+	if err != nil {
+		return err
+	}
+	repo, _ := Parse(ref)
+	m, err := r.FetchManifest(repo, entry.Digest.String())
+	if err != nil {
+		return fmt.Errorf("fetch of manifest %s failed: %s", entry.Digest, err)
+	}
+	config, err := fetchImageConfig(r, repo, m)
+	if err != nil {
+		return fmt.Errorf("fetch of image configuration of %s failed: %s", entry.Digest, err)
+	}
+	if err := checkPlatform(entry.Platform, config); err != nil {
+		return fmt.Errorf("platform of %s does not match its image: %s", entry.Digest, err)
+	}
+	return nil
+}
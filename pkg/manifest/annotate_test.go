@@ -0,0 +1,38 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+func TestPlanAnnotateExtraTags(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	putFakeList(r, "app", "1.4", amd64)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	annotation := &Annotation{
+		Entry:     "linux/amd64",
+		Platform:  manifestlist.PlatformSpec{OSVersion: "1"},
+		SkipCheck: true,
+	}
+	plan, _, err := PlanAnnotate(auth, nil, r.Host()+"/app:1.4", annotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.AddTags("1"); err != nil {
+		t.Fatal(err)
+	}
+	results, err := plan.Push()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if r.digest("app", result.Tag) != plan.Digest {
+			t.Errorf("tag %s was not annotated", result.Tag)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+// Provenance annotations, set on OCI image indexes when asked.
+const (
+	AnnotationCreated  = "org.opencontainers.image.created"
+	AnnotationSource   = "org.opencontainers.image.source"
+	AnnotationRevision = "org.opencontainers.image.revision"
+
+	// AnnotationSources lists the references of the source images of an
+	// index, separated by commas.
+	AnnotationSources = "io.github.sakeven.manifest.sources"
+)
+
+// ManifestAnnotation is an annotation of the entries of an OCI image index
+// matching a platform.
+type ManifestAnnotation struct {
+	Platform manifestlist.PlatformSpec
+	Key      string
+	Value    string
+}
+
+// ParseAnnotations parses annotations written as key=value.
+func ParseAnnotations(ss []string) (map[string]string, error) {
+	if len(ss) == 0 {
+		return nil, nil
+	}
+	annotations := make(map[string]string, len(ss))
+	for _, s := range ss {
+		key, value, err := parseAnnotation(s)
+		if err != nil {
+			return nil, err
+		}
+		annotations[key] = value
+	}
+	return annotations, nil
+}
+
+func parseAnnotation(s string) (string, string, error) {
+	parts := strings.SplitN(s, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", fmt.Errorf("invalid annotation %q, expected key=value", s)
+	}
+	return parts[0], parts[1], nil
+}
+
+// ParseManifestAnnotation parses an annotation of entries written as
+// <platform>:key=value, like linux/arm64:org.opencontainers.image.revision=abc.
+func ParseManifestAnnotation(s string) (ManifestAnnotation, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return ManifestAnnotation{}, fmt.Errorf("invalid manifest annotation %q, expected <platform>:key=value", s)
+	}
+	p, err := platforms.Parse(parts[0])
+	if err != nil {
+		return ManifestAnnotation{}, fmt.Errorf("invalid manifest annotation %q: %s", s, err)
+	}
+	key, value, err := parseAnnotation(parts[1])
+	if err != nil {
+		return ManifestAnnotation{}, fmt.Errorf("invalid manifest annotation %q: %s", s, err)
+	}
+	return ManifestAnnotation{Platform: p, Key: key, Value: value}, nil
+}
+
+// hasAnnotations returns true if the manifest list of a plan must carry
+// annotations, which only OCI image indexes can.
+func (p *Plan) hasAnnotations() bool {
+	return len(p.annotations) > 0 || len(p.entryAnnotations) > 0 ||
+		len(p.opts.Annotations) > 0 || len(p.opts.ManifestAnnotations) > 0
+}
+
+// indexAnnotations returns the annotations of the index of a plan: the ones
+// of the plan, overridden by the ones of the options.
+func (p *Plan) indexAnnotations() map[string]string {
+	return mergeAnnotations(p.annotations, p.opts.Annotations)
+}
+
+// manifestAnnotations returns the annotations of the entries, in order: the
+// ones of their source images, overridden by the manifest annotations of the
+// options matching their platform. Every manifest annotation must match an
+// entry.
+func (p *Plan) manifestAnnotations(manifests []manifestlist.ManifestDescriptor) ([]map[string]string, error) {
+	annotations := make([]map[string]string, len(manifests))
+	for i, m := range manifests {
+		annotations[i] = mergeAnnotations(p.entryAnnotations[m.Digest])
+	}
+	for _, a := range p.opts.ManifestAnnotations {
+		matched := false
+		for i, m := range manifests {
+			if !platforms.Match(a.Platform, m.Platform) {
+				continue
+			}
+			if annotations[i] == nil {
+				annotations[i] = make(map[string]string)
+			}
+			annotations[i][a.Key] = a.Value
+			matched = true
+		}
+		if !matched {
+			return nil, fmt.Errorf("no entry matches platform %s of annotation %s", platforms.Format(a.Platform), a.Key)
+		}
+	}
+	return annotations, nil
+}
+
+// addProvenance sets the provenance annotations of the source images on the
+// plan: the creation time, the references of the sources, and the source and
+// revision the images are labelled with, if they all agree. The creation time
+// is the one of the options, or else the one of the newest source image, so
+// that the same sources always give the same index.
+func (p *Plan) addProvenance(srcs []sourceImage) {
+	if p.annotations == nil {
+		p.annotations = make(map[string]string)
+	}
+	created := p.opts.Created
+	if created.IsZero() {
+		for _, src := range srcs {
+			if src.Image.Created.After(created) {
+				created = src.Image.Created
+			}
+		}
+	}
+	if !created.IsZero() {
+		p.annotations[AnnotationCreated] = created.UTC().Format(time.RFC3339)
+	}
+
+	refs := make([]string, len(srcs))
+	for i, src := range srcs {
+		refs[i] = src.Source.Image
+	}
+	p.annotations[AnnotationSources] = strings.Join(refs, ",")
+
+	for _, key := range []string{AnnotationSource, AnnotationRevision} {
+		var value string
+		for _, src := range srcs {
+			label := src.Image.Labels[key]
+			if label == "" {
+				continue
+			}
+			if value != "" && label != value {
+				log.Warnf("Source images have different %s labels, %s and %s, the annotation is not set", key, value, label)
+				value = ""
+				break
+			}
+			value = label
+		}
+		if value != "" {
+			p.annotations[key] = value
+		}
+	}
+}
+
+// mergeAnnotations merges annotations, later ones winning. It returns nil if
+// there are none.
+func mergeAnnotations(all ...map[string]string) map[string]string {
+	var merged map[string]string
+	for _, annotations := range all {
+		for k, v := range annotations {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[k] = v
+		}
+	}
+	return merged
+}
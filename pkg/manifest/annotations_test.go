@@ -0,0 +1,119 @@
+package manifest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestParseManifestAnnotation(t *testing.T) {
+	a, err := ParseManifestAnnotation("linux/aarch64:org.opencontainers.image.revision=a=b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if platforms.Format(a.Platform) != "linux/arm64" || a.Key != AnnotationRevision || a.Value != "a=b" {
+		t.Errorf("unexpected manifest annotation %#v", a)
+	}
+
+	for _, s := range []string{"key=value", "linux:key=value", "linux/amd64:key", "linux/amd64:=value"} {
+		if _, err := ParseManifestAnnotation(s); err == nil {
+			t.Errorf("ParseManifestAnnotation(%q) succeeded", s)
+		}
+	}
+	if _, err := ParseAnnotations([]string{"a=b", "c"}); err == nil {
+		t.Error("ParseAnnotations accepted an annotation without value")
+	}
+}
+
+func TestSetManifestsAnnotations(t *testing.T) {
+	amd64, _ := platforms.Parse("linux/amd64")
+	arm, _ := platforms.Parse("linux/arm/v7")
+	manifests := []manifestlist.ManifestDescriptor{
+		{Platform: amd64},
+		{Platform: arm},
+	}
+	for i := range manifests {
+		manifests[i].MediaType = schema2.MediaTypeManifest
+		manifests[i].Digest = digest.FromString(platforms.Format(manifests[i].Platform))
+		manifests[i].Size = 100
+	}
+
+	opts := &CreateOptions{
+		KeepOrder:           true,
+		Annotations:         map[string]string{"a": "flag"},
+		ManifestAnnotations: []ManifestAnnotation{{Platform: arm, Key: "x", Value: "y"}},
+	}
+	plan := &Plan{opts: opts, annotations: map[string]string{"a": "spec", "b": "spec"}}
+	if err := plan.setManifests(manifests); err != nil {
+		t.Fatal(err)
+	}
+	index, ok := plan.List.(*ocischema.DeserializedIndex)
+	if !ok {
+		t.Fatalf("annotations made a %T, want an OCI image index", plan.List)
+	}
+	if index.Annotations["a"] != "flag" || index.Annotations["b"] != "spec" {
+		t.Errorf("unexpected index annotations %v", index.Annotations)
+	}
+	if index.Manifests[0].Annotations != nil || index.Manifests[1].Annotations["x"] != "y" {
+		t.Errorf("unexpected entry annotations %v, %v", index.Manifests[0].Annotations, index.Manifests[1].Annotations)
+	}
+
+	opts.Format = FormatDocker
+	if err := plan.setManifests(manifests); err == nil {
+		t.Error("annotations were accepted by a docker manifest list")
+	}
+
+	s390x, _ := platforms.Parse("linux/s390x")
+	opts.Format = ""
+	opts.ManifestAnnotations = []ManifestAnnotation{{Platform: s390x, Key: "x", Value: "y"}}
+	if err := plan.setManifests(manifests); err == nil {
+		t.Error("an annotation matching no entry was accepted")
+	}
+}
+
+func TestMergeAnnotations(t *testing.T) {
+	if merged := mergeAnnotations(nil, map[string]string{}); merged != nil {
+		t.Errorf("mergeAnnotations of nothing = %v, want nil", merged)
+	}
+	merged := mergeAnnotations(map[string]string{"a": "1", "b": "1"}, map[string]string{"b": "2"})
+	if len(merged) != 2 || merged["a"] != "1" || merged["b"] != "2" {
+		t.Errorf("unexpected merged annotations %v", merged)
+	}
+}
+
+func TestAddProvenance(t *testing.T) {
+	older := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	srcs := []sourceImage{
+		{Source: Source{Image: "registry/app:amd64"}, Image: ImageInspect{Created: newer, Labels: map[string]string{AnnotationRevision: "abc"}}},
+		{Source: Source{Image: "registry/app:arm64"}, Image: ImageInspect{Created: older, Labels: map[string]string{AnnotationRevision: "def", AnnotationSource: "https://example.com/app"}}},
+	}
+
+	plan := &Plan{opts: &CreateOptions{}}
+	plan.addProvenance(srcs)
+	want := map[string]string{
+		AnnotationCreated: "2026-01-02T04:04:05Z",
+		AnnotationSources: "registry/app:amd64,registry/app:arm64",
+		AnnotationSource:  "https://example.com/app",
+	}
+	if len(plan.annotations) != len(want) {
+		t.Errorf("unexpected provenance annotations %v", plan.annotations)
+	}
+	for k, v := range want {
+		if plan.annotations[k] != v {
+			t.Errorf("annotation %s = %q, want %q", k, plan.annotations[k], v)
+		}
+	}
+
+	plan = &Plan{opts: &CreateOptions{Created: time.Unix(0, 0)}}
+	plan.addProvenance(srcs)
+	if created := plan.annotations[AnnotationCreated]; created != "1970-01-01T00:00:00Z" {
+		t.Errorf("created = %q, want the time of the options", created)
+	}
+}
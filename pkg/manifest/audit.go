@@ -0,0 +1,319 @@
+package manifest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+	"github.com/sakeven/manifest/pkg/registry"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
+	"github.com/opencontainers/go-digest"
+)
+
+// Statuses of an audited blob.
+const (
+	BlobHealthy = "healthy"
+	BlobCorrupt = "corrupt"
+	BlobMissing = "missing"
+	BlobFailed  = "failed"
+)
+
+// AuditOptions holds options about how blobs are audited.
+type AuditOptions struct {
+	// Deep downloads every blob to check its digest and size, instead of
+	// only checking that it exists with the right size.
+	Deep bool
+
+	// Jobs is the number of blobs audited at a time.
+	Jobs int
+
+	// StateFile records the result of every audited blob as it is known, so
+	// that an interrupted audit can be resumed. Blobs it records as healthy
+	// are not audited again.
+	StateFile string
+}
+
+// BlobReport is the result of the audit of a blob.
+type BlobReport struct {
+	Digest digest.Digest `json:"digest"`
+	Size   int64         `json:"size"`
+	Status string        `json:"status"`
+	Detail string        `json:"detail,omitempty"`
+
+	// Deep is true if the blob was downloaded and re-hashed.
+	Deep bool `json:"deep"`
+
+	// References are the tags, with the platform for entries of manifest
+	// lists, which reference the blob.
+	References []string `json:"references,omitempty"`
+}
+
+// AuditReport is the result of an audit.
+type AuditReport struct {
+	Image string `json:"image"`
+
+	// Manifests is the number of manifests walked.
+	Manifests int `json:"manifests"`
+
+	// Problems are the manifests which could not be walked.
+	Problems []string `json:"problems,omitempty"`
+
+	Blobs []BlobReport `json:"blobs"`
+}
+
+// Count returns the number of blobs of the given status.
+func (r *AuditReport) Count(status string) int {
+	n := 0
+	for _, blob := range r.Blobs {
+		if blob.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// OK returns true if every manifest was walked and every blob is healthy.
+func (r *AuditReport) OK() bool {
+	return len(r.Problems) == 0 && r.Count(BlobHealthy) == len(r.Blobs)
+}
+
+// Audit checks every configuration and layer blob referenced by an image, a
+// manifest list, or every tag of a repository if image has no tag. Foreign
+// layers are skipped, since they are not stored in the registry.
+func Audit(a *AuthInfo, image string, opts *AuditOptions) (*AuditReport, error) {
+	ref, err := reference.ParseNamed(image)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newClientPool(a).get(ref.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	repo, tag := Parse(ref)
+	tags := []string{tag}
+	if reference.IsNameOnly(ref) {
+		if tags, err = r.ListTags(repo); err != nil {
+			return nil, fmt.Errorf("failed to list tags of %s: %s", image, err)
+		}
+	}
+
+	state, err := loadAuditState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	stateFile, err := openAuditState(opts.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	if stateFile != nil {
+		defer stateFile.Close()
+	}
+
+	report := &AuditReport{Image: image}
+	w := newBlobWalker(r, repo)
+	for _, tag := range tags {
+		w.walk(tag, tag)
+	}
+	report.Manifests, report.Problems = len(w.manifests), w.problems
+
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = DefaultJobs
+	}
+	report.Blobs = make([]BlobReport, len(w.blobs))
+	var (
+		sem = make(chan struct{}, jobs)
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+	)
+	for i, desc := range w.blobs {
+		if previous, ok := state[desc.Digest]; ok && previous.Status == BlobHealthy && (previous.Deep || !opts.Deep) {
+			log.Debugf("Blob %s is already audited", desc.Digest)
+			report.Blobs[i] = previous
+			report.Blobs[i].References = w.references[desc.Digest]
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, desc distribution.Descriptor) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			blob := auditBlob(r, repo, desc, opts.Deep)
+			if stateFile != nil {
+				mu.Lock()
+				if err := json.NewEncoder(stateFile).Encode(blob); err != nil {
+					log.Errorf("Failed to record blob %s in %s: %s", desc.Digest, opts.StateFile, err)
+				}
+				mu.Unlock()
+			}
+			blob.References = w.references[desc.Digest]
+			report.Blobs[i] = blob
+		}(i, desc)
+	}
+	wg.Wait()
+	return report, nil
+}
+
+// blobWalker collects the blobs referenced by manifests of a repository.
+type blobWalker struct {
+	r    *registry.Client
+	repo string
+
+	// manifests are the manifests already fetched, by digest
+	manifests  map[digest.Digest]distribution.Manifest
+	blobs      []distribution.Descriptor
+	references map[digest.Digest][]string
+	problems   []string
+}
+
+func newBlobWalker(r *registry.Client, repo string) *blobWalker {
+	return &blobWalker{
+		r:          r,
+		repo:       repo,
+		manifests:  make(map[digest.Digest]distribution.Manifest),
+		references: make(map[digest.Digest][]string),
+	}
+}
+
+// walk collects the blobs of the manifest tagOrDigest, referenced as name.
+func (w *blobWalker) walk(tagOrDigest, name string) {
+	m, ok := w.manifests[digest.Digest(tagOrDigest)]
+	if !ok {
+		var err error
+		if m, err = w.r.FetchManifest(w.repo, tagOrDigest); err != nil {
+			w.problems = append(w.problems, fmt.Sprintf("%s: fetch of manifest %s failed: %s", name, tagOrDigest, err))
+			return
+		}
+	}
+	mediaType, payload, err := m.Payload()
+	if err != nil {
+		w.problems = append(w.problems, fmt.Sprintf("%s: %s", name, err))
+		return
+	}
+	w.manifests[digest.FromBytes(payload)] = m
+
+	if !IsManifestList(mediaType) {
+		w.addReferences(m, name)
+		return
+	}
+	entries, err := listEntries(m)
+	if err != nil {
+		w.problems = append(w.problems, fmt.Sprintf("%s: %s", name, err))
+		return
+	}
+	for _, entry := range entries {
+		w.walk(entry.Digest.String(), name+" "+platforms.Format(entry.Platform))
+	}
+}
+
+// addReferences records the blobs of an image manifest as referenced by name.
+func (w *blobWalker) addReferences(m distribution.Manifest, name string) {
+	for _, desc := range m.References() {
+		if IsForeignLayer(desc) {
+			continue
+		}
+		if _, ok := w.references[desc.Digest]; !ok {
+			w.blobs = append(w.blobs, desc)
+		}
+		w.references[desc.Digest] = appendUnique(w.references[desc.Digest], name)
+	}
+}
+
+// auditBlob checks a blob against its descriptor. If deep is true, the blob
+// is downloaded and re-hashed, otherwise only its existence and size are
+// checked.
+func auditBlob(r *registry.Client, repo string, desc distribution.Descriptor, deep bool) BlobReport {
+	report := BlobReport{Digest: desc.Digest, Size: desc.Size, Deep: deep}
+	setStatus := func(status, detail string, args ...interface{}) BlobReport {
+		report.Status, report.Detail = status, fmt.Sprintf(detail, args...)
+		return report
+	}
+
+	// digests come from remote manifests, and hashing with an unknown
+	// algorithm panics
+	if err := desc.Digest.Validate(); err != nil {
+		return setStatus(BlobCorrupt, "invalid digest: %s", err)
+	}
+
+	if !deep {
+		stat, err := r.HeadBlob(repo, desc.Digest.String())
+		if registry.IsNotFound(err) {
+			return setStatus(BlobMissing, "")
+		}
+		if err != nil {
+			return setStatus(BlobFailed, "%s", err)
+		}
+		if desc.Size > 0 && stat.Size >= 0 && stat.Size != desc.Size {
+			return setStatus(BlobCorrupt, "size is %d bytes, expected %d", stat.Size, desc.Size)
+		}
+		return setStatus(BlobHealthy, "")
+	}
+
+	blob, _, err := r.GetBlob(repo, desc.Digest.String())
+	if registry.IsNotFound(err) {
+		return setStatus(BlobMissing, "")
+	}
+	if err != nil {
+		return setStatus(BlobFailed, "%s", err)
+	}
+	defer blob.Close()
+
+	digester := desc.Digest.Algorithm().Digester()
+	size, err := io.Copy(digester.Hash(), blob)
+	if err != nil {
+		return setStatus(BlobFailed, "download failed after %d bytes: %s", size, err)
+	}
+	if desc.Size > 0 && size != desc.Size {
+		return setStatus(BlobCorrupt, "size is %d bytes, expected %d", size, desc.Size)
+	}
+	if actual := digester.Digest(); actual != desc.Digest {
+		return setStatus(BlobCorrupt, "digest is %s", actual)
+	}
+	return setStatus(BlobHealthy, "")
+}
+
+// loadAuditState reads the blobs recorded in a state file, if it exists. The
+// last record of a blob wins.
+func loadAuditState(path string) (map[digest.Digest]BlobReport, error) {
+	state := make(map[digest.Digest]BlobReport)
+	if path == "" {
+		return state, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var blob BlobReport
+		if err := json.Unmarshal(scanner.Bytes(), &blob); err != nil {
+			// the last line may be cut short by an interruption
+			log.Warnf("Ignoring line %d of audit state %s: %s", line, path, err)
+			continue
+		}
+		state[blob.Digest] = blob
+	}
+	return state, scanner.Err()
+}
+
+func openAuditState(path string) (*os.File, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
@@ -0,0 +1,107 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestLoadAuditState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, b := digest.FromString("a"), digest.FromString("b")
+	path := filepath.Join(dir, "state")
+	content := `{"digest":"` + a.String() + `","size":1,"status":"failed"}
+{"digest":"` + a.String() + `","size":1,"status":"healthy","deep":true}
+{"digest":"` + b.String() + `","size":2,"sta`
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := loadAuditState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state) != 1 {
+		t.Fatalf("state has %d blobs, want 1 since the last line is cut short", len(state))
+	}
+	if blob := state[a]; blob.Status != BlobHealthy || !blob.Deep {
+		t.Errorf("blob %s is %+v, want the last record", a, blob)
+	}
+
+	if state, err := loadAuditState(filepath.Join(dir, "missing")); err != nil || len(state) != 0 {
+		t.Errorf("missing state file gives %v, %v, want an empty state", state, err)
+	}
+}
+
+func TestAuditBlobInvalidDigest(t *testing.T) {
+	for _, dgst := range []digest.Digest{"md5:d41d8cd98f00b204e9800998ecf8427e", "sha256:garbage", "garbage"} {
+		report := auditBlob(nil, "app", distribution.Descriptor{Digest: dgst}, true)
+		if report.Status != BlobCorrupt {
+			t.Errorf("audit of %s gave status %s, want %s", dgst, report.Status, BlobCorrupt)
+		}
+	}
+}
+
+func TestAuditDeep(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the corrupt layer is stored with other content of the same size
+	corrupt := r.putBlob("app", schema2.MediaTypeLayer, []byte("good"))
+	r.mu.Lock()
+	r.blobs["app@"+corrupt.Digest.String()] = []byte("bad!")
+	r.mu.Unlock()
+	missing := distribution.Descriptor{MediaType: schema2.MediaTypeLayer, Size: 7, Digest: digest.FromString("missing")}
+	config := fakeConfig(manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}, nil)
+	r.putImage("app", "1.4", config, corrupt, missing)
+	healthy := digest.FromBytes(config)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	opts := &AuditOptions{Deep: true, StateFile: filepath.Join(dir, "state")}
+	want := map[digest.Digest]string{
+		healthy:        BlobHealthy,
+		corrupt.Digest: BlobCorrupt,
+		missing.Digest: BlobMissing,
+	}
+	for run := 1; run <= 2; run++ {
+		report, err := Audit(auth, r.Host()+"/app:1.4", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.OK() || len(report.Blobs) != len(want) {
+			t.Errorf("run %d: report is %+v", run, report)
+		}
+		for _, blob := range report.Blobs {
+			if blob.Status != want[blob.Digest] || !blob.Deep {
+				t.Errorf("run %d: blob %s is %s (%s), want %s", run, blob.Digest, blob.Status, blob.Detail, want[blob.Digest])
+			}
+			if blob.Digest == corrupt.Digest && blob.Detail != "digest is "+digest.FromString("bad!").String() {
+				t.Errorf("run %d: corrupt blob detail is %q", run, blob.Detail)
+			}
+		}
+	}
+
+	// the second run only downloaded the blobs which were not healthy
+	if reqs := r.requested("GET /v2/app/blobs/" + healthy.String()); len(reqs) != 1 {
+		t.Errorf("the healthy blob was downloaded %d times, want once", len(reqs))
+	}
+	if reqs := r.requested("GET /v2/app/blobs/" + corrupt.Digest.String()); len(reqs) != 2 {
+		t.Errorf("the corrupt blob was downloaded %d times, want twice", len(reqs))
+	}
+}
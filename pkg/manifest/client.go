@@ -0,0 +1,39 @@
+package manifest
+
+import (
+	"sync"
+
+	"github.com/sakeven/manifest/pkg/registry"
+)
+
+// clientPool shares one registry client per host, so that concurrent
+// operations against the same registry reuse its connections.
+type clientPool struct {
+	auth *AuthInfo
+
+	mu      sync.Mutex
+	clients map[string]*registry.Client
+}
+
+func newClientPool(a *AuthInfo) *clientPool {
+	return &clientPool{
+		auth:    a,
+		clients: make(map[string]*registry.Client),
+	}
+}
+
+// get returns the client for host, creating it on first use.
+func (p *clientPool) get(host string) (*registry.Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if r, ok := p.clients[host]; ok {
+		return r, nil
+	}
+	r, err := GetHTTPClient(p.auth, host)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[host] = r
+	return r, nil
+}
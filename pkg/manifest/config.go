@@ -0,0 +1,123 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/registry"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/docker/image"
+	"github.com/opencontainers/go-digest"
+)
+
+// ImageConfig is the configuration of an image, along with the fields the
+// vendored docker image type does not know about.
+type ImageConfig struct {
+	*image.Image
+
+	// Variant is the variant of the CPU, for example `v7` for arm.
+	Variant string
+
+	// Raw is the configuration blob.
+	Raw []byte
+}
+
+// ParseImageConfig parses an image configuration blob.
+func ParseImageConfig(blob []byte) (*ImageConfig, error) {
+	img, err := image.NewFromJSON(blob)
+	if err != nil {
+		return nil, err
+	}
+
+	var extra struct {
+		Variant string `json:"variant"`
+	}
+	if err := json.Unmarshal(blob, &extra); err != nil {
+		return nil, err
+	}
+	return &ImageConfig{Image: img, Variant: extra.Variant, Raw: blob}, nil
+}
+
+// VariantLabels are the image labels the CPU variant is read from, when the
+// image configuration has none.
+var VariantLabels = []string{
+	"org.opencontainers.image.variant",
+	"variant",
+}
+
+// Platform returns the platform declared by the image configuration. If the
+// configuration has no variant, it is read from VariantLabels.
+func (c *ImageConfig) Platform() manifestlist.PlatformSpec {
+	p := manifestlist.PlatformSpec{
+		Architecture: c.Architecture,
+		OS:           c.OS,
+		OSVersion:    c.OSVersion,
+		OSFeatures:   c.OSFeatures,
+		Variant:      c.Variant,
+	}
+	if p.Variant == "" {
+		p.Variant = c.Label(VariantLabels...)
+	}
+	return p
+}
+
+// Label returns the value of the first of keys the image is labelled with.
+func (c *ImageConfig) Label(keys ...string) string {
+	if c.Config == nil {
+		return ""
+	}
+	for _, key := range keys {
+		if v, ok := c.Config.Labels[key]; ok && v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// fetchImageConfig pulls and parses the configuration of an image manifest.
+func fetchImageConfig(r *registry.Client, repository string, m distribution.Manifest) (*ImageConfig, error) {
+	var configDigest digest.Digest
+	switch v := m.(type) {
+	case *schema2.DeserializedManifest:
+		configDigest = v.Config.Digest
+	case *ocischema.DeserializedManifest:
+		configDigest = v.Config.Digest
+	default:
+		mediaType, _, _ := m.Payload()
+		return nil, fmt.Errorf("manifest of type %s has no image configuration", mediaType)
+	}
+
+	blob, err := r.PullBlob(repository, configDigest.String())
+	if err != nil {
+		return nil, err
+	}
+	return ParseImageConfig(blob)
+}
+
+// checkPlatform checks a declared platform against the image configuration
+// it describes, once both are normalized, so that an arm64 entry matches an
+// aarch64 image. The variant and OS version are only checked if the
+// configuration has them.
+func checkPlatform(declared manifestlist.PlatformSpec, config *ImageConfig) error {
+	var errs Errors
+	actual := config.Platform()
+	normDeclared, normActual := platforms.Normalize(declared), platforms.Normalize(actual)
+	if normDeclared.OS != normActual.OS {
+		errs = append(errs, fmt.Errorf("os is %q, but the image is %q", declared.OS, actual.OS))
+	}
+	if normDeclared.Architecture != normActual.Architecture {
+		errs = append(errs, fmt.Errorf("architecture is %q, but the image is %q", declared.Architecture, actual.Architecture))
+	}
+	if actual.Variant != "" && normDeclared.Variant != normActual.Variant {
+		errs = append(errs, fmt.Errorf("variant is %q, but the image is %q", declared.Variant, actual.Variant))
+	}
+	if actual.OSVersion != "" && declared.OSVersion != actual.OSVersion {
+		errs = append(errs, fmt.Errorf("os.version is %q, but the image is %q", declared.OSVersion, actual.OSVersion))
+	}
+	return errs.errOrNil()
+}
@@ -0,0 +1,35 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+func TestImageConfigPlatform(t *testing.T) {
+	tests := map[string]string{
+		`{"os":"linux","architecture":"arm","variant":"v6","rootfs":{"type":"layers"}}`:                                                "linux/arm/v6",
+		`{"os":"linux","architecture":"arm","config":{"Labels":{"org.opencontainers.image.variant":"v7"}},"rootfs":{"type":"layers"}}`: "linux/arm/v7",
+		`{"os":"linux","architecture":"arm","variant":"v6","config":{"Labels":{"variant":"v7"}},"rootfs":{"type":"layers"}}`:           "linux/arm/v6",
+		`{"os":"linux","architecture":"aarch64","rootfs":{"type":"layers"}}`:                                                           "linux/arm64",
+	}
+	for blob, want := range tests {
+		config, err := ParseImageConfig([]byte(blob))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := platforms.Format(platforms.Canonical(config.Platform())); got != want {
+			t.Errorf("platform of %s is %s, want %s", blob, got, want)
+		}
+	}
+}
+
+func TestOverridePlatform(t *testing.T) {
+	p, _ := platforms.Parse("linux/arm")
+	p = overridePlatform(p, manifestlist.PlatformSpec{Architecture: "armhf"})
+	if got := platforms.Format(p); got != "linux/arm/v7" {
+		t.Errorf("overridden platform is %s, want linux/arm/v7", got)
+	}
+}
@@ -0,0 +1,311 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+// ConvertOptions holds options about how images are converted.
+type ConvertOptions struct {
+	// Format is the format to convert to, FormatDocker or FormatOCI.
+	Format string
+
+	// Force overwrites a destination tag which points to a different
+	// manifest. Converting in place only needs it if the tag changed since
+	// it was fetched.
+	Force bool
+}
+
+// ociMediaTypes maps the media types of docker image manifests to their OCI
+// equivalents. dockerMediaTypes is the reverse mapping.
+var (
+	ociMediaTypes = map[string]string{
+		schema2.MediaTypeManifest:          ocischema.MediaTypeImageManifest,
+		schema2.MediaTypeImageConfig:       ocischema.MediaTypeImageConfig,
+		schema2.MediaTypeLayer:             ocischema.MediaTypeImageLayerGzip,
+		schema2.MediaTypeUncompressedLayer: ocischema.MediaTypeImageLayer,
+		schema2.MediaTypeForeignLayer:      ocischema.MediaTypeImageLayerNonDistributableGzip,
+	}
+	dockerMediaTypes = reverseMediaTypes(ociMediaTypes)
+)
+
+func reverseMediaTypes(m map[string]string) map[string]string {
+	reversed := make(map[string]string, len(m))
+	for k, v := range m {
+		reversed[v] = k
+	}
+	return reversed
+}
+
+// Convert converts an image or a manifest list between the docker and OCI
+// formats: the manifest list, its image manifests, and the media types of
+// their configurations and layers. The converted image manifests are pushed
+// by digest, then the converted manifest list is pushed to dst. Blobs keep
+// their digests, and are copied like Copy does if dst is another repository.
+//
+// If dst is empty, the image is converted in place. Unless forced, the tag is
+// then only overwritten if it still points to the converted manifest.
+func Convert(a *AuthInfo, src, dst string, opts *ConvertOptions) ([]TagResult, error) {
+	if opts.Format != FormatDocker && opts.Format != FormatOCI {
+		return nil, fmt.Errorf("unknown manifest list format %q, expected %s or %s", opts.Format, FormatDocker, FormatOCI)
+	}
+	srcRef, err := reference.ParseNamed(src)
+	if err != nil {
+		return nil, err
+	}
+	dstRef := srcRef
+	if dst != "" {
+		if dstRef, err = reference.ParseNamed(dst); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := dstRef.(reference.Canonical); ok {
+		return nil, fmt.Errorf("destination %s must not have a digest, since converting changes it", dstRef)
+	}
+
+	c, err := newCopier(newClientPool(a), srcRef, dstRef, &CopyOptions{Force: opts.Force})
+	if err != nil {
+		return nil, err
+	}
+	_, srcTag := Parse(srcRef)
+	_, dstTag := Parse(dstRef)
+	if reference.IsNameOnly(dstRef) {
+		dstTag = srcTag
+	}
+	if _, err := digest.Parse(dstTag); err == nil {
+		return nil, fmt.Errorf("a destination tag is needed, since converting changes the digest")
+	}
+	result := c.convertTag(srcTag, dstTag, opts)
+	return []TagResult{result}, result.Err
+}
+
+// convertTag converts the image or manifest list srcTag points to, and makes
+// dstTag point to the result.
+func (c *copier) convertTag(srcTag, dstTag string, opts *ConvertOptions) TagResult {
+	fail := func(err error) TagResult {
+		return TagResult{Tag: dstTag, Err: fmt.Errorf("conversion of %s:%s failed: %s", c.srcRepo, srcTag, err)}
+	}
+
+	imgs, err := Inspect(c.src, c.srcRepo, srcTag)
+	if err != nil {
+		return fail(err)
+	}
+	if len(imgs) == 0 {
+		return fail(fmt.Errorf("unsupported manifest"))
+	}
+
+	var (
+		m    distribution.Manifest
+		dgst digest.Digest
+	)
+	if IsManifestList(imgs[0].MediaType) {
+		entries := make(map[digest.Digest]distribution.Descriptor)
+		for _, img := range imgs[1:] {
+			desc, err := c.convertManifest(img, opts.Format)
+			if err != nil {
+				return fail(fmt.Errorf("entry %s: %s", platforms.Format(img.Platform), err))
+			}
+			entries[img.Digest] = desc
+		}
+		if m, dgst, err = convertList(imgs[0].Manifest, entries, opts.Format); err != nil {
+			return fail(err)
+		}
+	} else {
+		if m, dgst, err = convertImageManifest(imgs[0].Manifest, opts.Format); err != nil {
+			return fail(err)
+		}
+		if err := c.copyBlobs(m.References()); err != nil {
+			return fail(err)
+		}
+	}
+
+	createOpts := &CreateOptions{Force: opts.Force}
+	if c.sameHub && c.srcRepo == c.dstRepo && srcTag == dstTag && !opts.Force {
+		// compare-then-set, so that concurrent changes are not lost
+		createOpts.IfDigest = imgs[0].Digest.String()
+	}
+	return pushTag(c.dst, createOpts, c.dstRepo, dstTag, m, dgst)
+}
+
+// convertManifest converts an image manifest, copies its blobs, and pushes it
+// by digest unless it is already in the destination repository. It returns
+// the descriptor of the converted manifest.
+func (c *copier) convertManifest(img ImageInspect, format string) (distribution.Descriptor, error) {
+	m, dgst, err := convertImageManifest(img.Manifest, format)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	mediaType, payload, err := m.Payload()
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	desc := distribution.Descriptor{MediaType: mediaType, Size: int64(len(payload)), Digest: dgst}
+
+	current, err := currentDigest(c.dst, c.dstRepo, dgst.String())
+	if err != nil {
+		return desc, err
+	}
+	if current == dgst {
+		log.Debugf("Manifest %s already exists in %s", dgst, c.dstRepo)
+		return desc, nil
+	}
+	if err := c.copyBlobs(m.References()); err != nil {
+		return desc, err
+	}
+	log.Debugf("Push manifest %s converted from %s", dgst, img.Digest)
+	_, err = c.dst.PushManifest(c.dstRepo, dgst.String(), m)
+	return desc, err
+}
+
+// convertImageManifest converts a docker or OCI image manifest to format. A
+// manifest already in format is returned as is, so that its digest is kept.
+func convertImageManifest(m distribution.Manifest, format string) (distribution.Manifest, digest.Digest, error) {
+	var converted distribution.Manifest
+	switch v := m.(type) {
+	case *schema2.DeserializedManifest:
+		if format == FormatDocker {
+			converted = m
+			break
+		}
+		layers := make([]ocischema.Descriptor, len(v.Layers))
+		for i, layer := range v.Layers {
+			d, err := convertDescriptor(layer, format)
+			if err != nil {
+				return nil, "", err
+			}
+			layers[i] = ocischema.Descriptor{MediaType: d.MediaType, Size: d.Size, Digest: d.Digest, URLs: d.URLs}
+		}
+		config, err := convertDescriptor(v.Config, format)
+		if err != nil {
+			return nil, "", err
+		}
+		oci := ocischema.Manifest{
+			Versioned: ocischema.SchemaVersion,
+			Config:    ocischema.Descriptor{MediaType: config.MediaType, Size: config.Size, Digest: config.Digest, URLs: config.URLs},
+			Layers:    layers,
+		}
+		if converted, err = ocischema.FromStruct(oci); err != nil {
+			return nil, "", err
+		}
+	case *ocischema.DeserializedManifest:
+		if format == FormatOCI {
+			converted = m
+			break
+		}
+		if len(v.Annotations) > 0 {
+			log.Warnf("Docker image manifests have no annotations, the annotations of the manifest are dropped")
+		}
+		descAnnotations := []map[string]string{v.Config.Annotations}
+		layers := make([]distribution.Descriptor, len(v.Layers))
+		for i, layer := range v.Layers {
+			d, err := convertDescriptor(layer.Descriptor(), format)
+			if err != nil {
+				return nil, "", err
+			}
+			layers[i] = d
+			descAnnotations = append(descAnnotations, layer.Annotations)
+		}
+		if mergeAnnotations(descAnnotations...) != nil {
+			log.Warnf("Docker descriptors have no annotations, the annotations of the configuration and layers are dropped")
+		}
+		config, err := convertDescriptor(v.Config.Descriptor(), format)
+		if err != nil {
+			return nil, "", err
+		}
+		docker := schema2.Manifest{
+			Versioned: schema2.SchemaVersion,
+			Config:    config,
+			Layers:    layers,
+		}
+		if converted, err = schema2.FromStruct(docker); err != nil {
+			return nil, "", err
+		}
+	default:
+		mediaType, _, _ := m.Payload()
+		return nil, "", fmt.Errorf("cannot convert manifest of type %s", mediaType)
+	}
+
+	_, payload, err := converted.Payload()
+	if err != nil {
+		return nil, "", err
+	}
+	return converted, digest.FromBytes(payload), nil
+}
+
+// convertDescriptor returns desc with its media type converted to format.
+func convertDescriptor(desc distribution.Descriptor, format string) (distribution.Descriptor, error) {
+	mediaTypes, same := ociMediaTypes, dockerMediaTypes
+	if format == FormatDocker {
+		mediaTypes, same = dockerMediaTypes, ociMediaTypes
+	}
+	if _, ok := same[desc.MediaType]; ok {
+		return desc, nil
+	}
+	mediaType, ok := mediaTypes[desc.MediaType]
+	if !ok {
+		return desc, fmt.Errorf("blob %s has media type %s, which has no %s equivalent", desc.Digest, desc.MediaType, format)
+	}
+	desc.MediaType = mediaType
+	return desc, nil
+}
+
+// convertList converts a docker manifest list or an OCI image index to
+// format, replacing its entries by their converted manifests. Platforms are
+// kept, and annotations when converting to OCI.
+func convertList(m distribution.Manifest, converted map[digest.Digest]distribution.Descriptor, format string) (distribution.Manifest, digest.Digest, error) {
+	manifests, err := listEntries(m)
+	if err != nil {
+		return nil, "", err
+	}
+	var (
+		annotations      map[string]string
+		entryAnnotations = make([]map[string]string, len(manifests))
+	)
+	if index, ok := m.(*ocischema.DeserializedIndex); ok {
+		annotations = index.Annotations
+		for i, desc := range index.Manifests {
+			entryAnnotations[i] = desc.Annotations
+		}
+	}
+
+	entries := make([]manifestlist.ManifestDescriptor, len(manifests))
+	for i, entry := range manifests {
+		desc, ok := converted[entry.Digest]
+		if !ok {
+			return nil, "", fmt.Errorf("entry %s was not converted", entry.Digest)
+		}
+		entries[i] = manifestlist.ManifestDescriptor{Descriptor: desc, Platform: entry.Platform}
+	}
+
+	var list distribution.Manifest
+	switch format {
+	case FormatOCI:
+		descs := toOCIDescriptors(entries)
+		for i := range descs {
+			descs[i].Annotations = entryAnnotations[i]
+		}
+		list, err = ocischema.FromDescriptors(descs, annotations)
+	default:
+		if mergeAnnotations(append(entryAnnotations, annotations)...) != nil {
+			log.Warnf("Docker manifest lists have no annotations, the annotations of the index are dropped")
+		}
+		list, err = manifestlist.FromDescriptors(entries)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("cannot deserialize manifest list: %s", err)
+	}
+	_, payload, err := list.Payload()
+	if err != nil {
+		return nil, "", err
+	}
+	return list, digest.FromBytes(payload), nil
+}
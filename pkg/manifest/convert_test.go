@@ -0,0 +1,149 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestConvertImageManifest(t *testing.T) {
+	docker, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    distribution.Descriptor{MediaType: schema2.MediaTypeImageConfig, Size: 10, Digest: digest.FromString("config")},
+		Layers: []distribution.Descriptor{
+			{MediaType: schema2.MediaTypeLayer, Size: 20, Digest: digest.FromString("layer")},
+			{MediaType: schema2.MediaTypeForeignLayer, Size: 30, Digest: digest.FromString("foreign"), URLs: []string{"https://example.com/foreign"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, payload, _ := docker.Payload()
+	dockerDigest := digest.FromBytes(payload)
+
+	m, dgst, err := convertImageManifest(docker, FormatOCI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oci, ok := m.(*ocischema.DeserializedManifest)
+	if !ok {
+		t.Fatalf("converted to %T, want an OCI image manifest", m)
+	}
+	if oci.Config.MediaType != ocischema.MediaTypeImageConfig ||
+		oci.Layers[0].MediaType != ocischema.MediaTypeImageLayerGzip ||
+		oci.Layers[1].MediaType != ocischema.MediaTypeImageLayerNonDistributableGzip ||
+		len(oci.Layers[1].URLs) != 1 {
+		t.Errorf("unexpected OCI image manifest %#v", oci.Manifest)
+	}
+
+	if _, same, _ := convertImageManifest(oci, FormatOCI); same != dgst {
+		t.Errorf("converting to the same format changed the digest from %s to %s", dgst, same)
+	}
+	if _, back, err := convertImageManifest(oci, FormatDocker); err != nil || back != dockerDigest {
+		t.Errorf("converting back gave digest %s (%v), want %s", back, err, dockerDigest)
+	}
+
+	oci.Layers[0].MediaType = "application/vnd.oci.image.layer.v1.tar+zstd"
+	if _, _, err := convertImageManifest(oci, FormatDocker); err == nil {
+		t.Error("a zstd layer was converted to docker")
+	}
+}
+
+func TestConvertList(t *testing.T) {
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	old, converted := digest.FromString("docker"), digest.FromString("oci")
+	list, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{{
+		Descriptor: distribution.Descriptor{MediaType: schema2.MediaTypeManifest, Size: 10, Digest: old},
+		Platform:   amd64,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := map[digest.Digest]distribution.Descriptor{
+		old: {MediaType: ocischema.MediaTypeImageManifest, Size: 12, Digest: converted},
+	}
+	m, _, err := convertList(list, entries, FormatOCI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, ok := m.(*ocischema.DeserializedIndex)
+	if !ok {
+		t.Fatalf("converted to %T, want an OCI image index", m)
+	}
+	if len(index.Manifests) != 1 || index.Manifests[0].Digest != converted || index.Manifests[0].Platform.Architecture != amd64.Architecture {
+		t.Errorf("unexpected OCI image index entries %#v", index.Manifests)
+	}
+
+	if _, _, err := convertList(list, nil, FormatOCI); err == nil {
+		t.Error("an entry which was not converted was accepted")
+	}
+}
+
+func TestConvertInPlace(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	layer := r.putBlob("app", schema2.MediaTypeLayer, []byte("layer"))
+	entries := []manifestlist.ManifestDescriptor{
+		{Descriptor: r.putImage("app", "", fakeConfig(amd64, nil), layer), Platform: amd64},
+		{Descriptor: putFakeImage(r, "app", "", arm64), Platform: arm64},
+	}
+	old := putFakeListOf(r, "app", "1.4", entries...)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	results, err := Convert(auth, r.Host()+"/app:1.4", "", &ConvertOptions{Format: FormatOCI})
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted := r.digest("app", "1.4")
+	if converted == old || results[0].Digest != converted {
+		t.Fatalf("1.4 is %s, want the converted index %s", converted, results[0].Digest)
+	}
+	index := mustFetch(t, r, "app", converted).(*ocischema.DeserializedIndex)
+
+	// the converted entries are pushed by digest before the index
+	writes := r.written("PUT /v2/app/manifests/")
+	want := []string{
+		"PUT /v2/app/manifests/" + index.Manifests[0].Digest.String(),
+		"PUT /v2/app/manifests/" + index.Manifests[1].Digest.String(),
+		"PUT /v2/app/manifests/1.4",
+	}
+	if strings.Join(writes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("writes are %v, want %v", writes, want)
+	}
+	for i, desc := range index.Manifests {
+		m, dgst, err := convertImageManifest(mustFetch(t, r, "app", entries[i].Digest), FormatOCI)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc.Digest != dgst || desc.MediaType != ocischema.MediaTypeImageManifest {
+			t.Errorf("entry %d is %s, want %s", i, desc.Digest, dgst)
+		}
+		if _, ok := m.(*ocischema.DeserializedManifest); !ok {
+			t.Errorf("entry %d is converted to %T", i, m)
+		}
+	}
+
+	// the tag moves while the entries are pushed, so it is not overwritten
+	old = putFakeList(r, "app", "2.0", manifestlist.PlatformSpec{OS: "linux", Architecture: "s390x"})
+	other := putFakeList(r, "app", "other", amd64)
+	r.onWrite = func(line string) {
+		r.manifests["app:2.0"] = r.manifests["app@"+other.String()]
+	}
+	_, err = Convert(auth, r.Host()+"/app:2.0", "", &ConvertOptions{Format: FormatOCI})
+	if err == nil || !strings.Contains(err.Error(), "expected "+old.String()) {
+		t.Errorf("Convert() = %v, want 2.0 refused since it moved", err)
+	}
+	if r.digest("app", "2.0") != other {
+		t.Errorf("2.0 is %s, want the concurrent change %s kept", r.digest("app", "2.0"), other)
+	}
+}
@@ -0,0 +1,297 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+	"github.com/sakeven/manifest/pkg/registry"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+// CopyOptions holds options about how images are copied.
+type CopyOptions struct {
+	// Platforms selects the entries of a manifest list to copy. All entries
+	// are copied if it is empty.
+	Platforms []manifestlist.PlatformSpec
+
+	// AllTags copies every tag of the source repository to the destination
+	// repository.
+	AllTags bool
+
+	// Force overwrites tags which already point to a different manifest.
+	Force bool
+}
+
+// copier copies manifests and their blobs from one repository to another.
+type copier struct {
+	src, dst         *registry.Client
+	srcRepo, dstRepo string
+	sameHub          bool
+	opts             *CopyOptions
+
+	// blobs which are known to be in the destination repository
+	copied map[digest.Digest]bool
+}
+
+// Copy copies an image or a manifest list, with all the manifests and blobs it
+// references, from src to dst. The digests of the manifests are preserved,
+// except for the one of a manifest list of which only some platforms are
+// copied. Blobs are mounted across repositories of the same registry and
+// streamed between registries.
+func Copy(a *AuthInfo, src, dst string, opts *CopyOptions) ([]TagResult, error) {
+	srcRef, err := reference.ParseNamed(src)
+	if err != nil {
+		return nil, err
+	}
+	dstRef, err := reference.ParseNamed(dst)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := dstRef.(reference.Canonical); ok {
+		return nil, fmt.Errorf("destination %s must not have a digest", dst)
+	}
+
+	c, err := newCopier(newClientPool(a), srcRef, dstRef, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !opts.AllTags {
+		_, srcTag := Parse(srcRef)
+		_, dstTag := Parse(dstRef)
+		if reference.IsNameOnly(dstRef) {
+			dstTag = srcTag
+		}
+		result := c.copyTag(srcTag, dstTag)
+		return []TagResult{result}, result.Err
+	}
+
+	if !reference.IsNameOnly(srcRef) || !reference.IsNameOnly(dstRef) {
+		return nil, fmt.Errorf("source and destination must be repositories without a tag to copy all tags")
+	}
+	tags, err := c.src.ListTags(c.srcRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags of %s: %s", src, err)
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("repository %s has no tags", src)
+	}
+
+	var (
+		results []TagResult
+		failed  Errors
+	)
+	for _, tag := range tags {
+		result := c.copyTag(tag, tag)
+		if result.Err != nil {
+			failed = append(failed, result.Err)
+		}
+		results = append(results, result)
+	}
+	return results, failed.errOrNil()
+}
+
+func newCopier(clients *clientPool, srcRef, dstRef reference.Named, opts *CopyOptions) (*copier, error) {
+	c := &copier{
+		srcRepo: srcRef.RemoteName(),
+		dstRepo: dstRef.RemoteName(),
+		sameHub: isSameHub(srcRef, dstRef),
+		opts:    opts,
+		copied:  make(map[digest.Digest]bool),
+	}
+	var err error
+	if c.src, err = clients.get(srcRef.Hostname()); err != nil {
+		return nil, err
+	}
+	if c.dst, err = clients.get(dstRef.Hostname()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// copyTag copies the image or manifest list srcTag points to, and makes
+// dstTag point to it. srcTag and dstTag can also be digests.
+func (c *copier) copyTag(srcTag, dstTag string) TagResult {
+	src := c.srcRepo + ":" + srcTag
+	if _, err := digest.Parse(srcTag); err == nil {
+		src = c.srcRepo + "@" + srcTag
+	}
+	fail := func(err error) TagResult {
+		return TagResult{Tag: dstTag, Err: fmt.Errorf("copy of %s failed: %s", src, err)}
+	}
+
+	imgs, err := Inspect(c.src, c.srcRepo, srcTag)
+	if err != nil {
+		return fail(err)
+	}
+	if len(imgs) == 0 {
+		return fail(fmt.Errorf("unsupported manifest"))
+	}
+
+	m, dgst := imgs[0].Manifest, imgs[0].Digest
+	if IsManifestList(imgs[0].MediaType) {
+		entries := selectEntries(imgs[1:], c.opts.Platforms)
+		if len(entries) == 0 {
+			return fail(fmt.Errorf("no entry matches the platforms to copy"))
+		}
+		for _, entry := range entries {
+			if err := c.copyManifest(entry, entry.Digest.String()); err != nil {
+				return fail(fmt.Errorf("entry %s: %s", platforms.Format(entry.Platform), err))
+			}
+		}
+		if len(entries) < len(imgs)-1 {
+			if m, dgst, err = filterList(m, entries); err != nil {
+				return fail(err)
+			}
+		}
+	} else {
+		if len(c.opts.Platforms) > 0 && len(selectEntries(imgs, c.opts.Platforms)) == 0 {
+			return fail(fmt.Errorf("image %s does not match the platforms to copy", platforms.Format(imgs[0].Platform)))
+		}
+		if err := c.copyBlobs(m.References()); err != nil {
+			return fail(err)
+		}
+	}
+
+	if _, err := digest.Parse(dstTag); err == nil && digest.Digest(dstTag) != dgst {
+		return fail(fmt.Errorf("a destination tag is needed, since the digest changes when only some platforms are copied"))
+	}
+	return pushTag(c.dst, &CreateOptions{Force: c.opts.Force}, c.dstRepo, dstTag, m, dgst)
+}
+
+// copyManifest copies the blobs of an image manifest, then pushes it by
+// digest, unless it is already in the destination repository.
+func (c *copier) copyManifest(img ImageInspect, tag string) error {
+	current, err := currentDigest(c.dst, c.dstRepo, img.Digest.String())
+	if err != nil {
+		return err
+	}
+	if current == img.Digest {
+		log.Debugf("Manifest %s already exists in %s", img.Digest, c.dstRepo)
+		return nil
+	}
+
+	if err := c.copyBlobs(img.Manifest.References()); err != nil {
+		return err
+	}
+	_, err = c.dst.PushManifest(c.dstRepo, tag, img.Manifest)
+	return err
+}
+
+// copyBlobs copies blobs which are not in the destination repository yet.
+// Foreign layers are skipped, since they are pulled from their URLs.
+func (c *copier) copyBlobs(descs []distribution.Descriptor) error {
+	for _, desc := range descs {
+		if IsForeignLayer(desc) {
+			log.Debugf("Skip foreign layer %s", desc.Digest)
+			continue
+		}
+		if c.copied[desc.Digest] {
+			continue
+		}
+		if err := c.copyBlob(desc); err != nil {
+			return fmt.Errorf("copy of blob %s failed: %s", desc.Digest, err)
+		}
+		c.copied[desc.Digest] = true
+	}
+	return nil
+}
+
+func (c *copier) copyBlob(desc distribution.Descriptor) error {
+	exists, err := c.dst.BlobExists(c.dstRepo, desc.Digest.String())
+	if err != nil || exists {
+		return err
+	}
+
+	if c.sameHub {
+		if _, err := c.dst.MountBlob(c.dstRepo, desc.Digest.String(), c.srcRepo); err != nil {
+			return err
+		}
+		// the registry may start a regular upload instead of mounting
+		exists, err := c.dst.BlobExists(c.dstRepo, desc.Digest.String())
+		if err != nil || exists {
+			return err
+		}
+		log.Debugf("Mount of blob %s was refused, stream it", desc.Digest)
+	}
+
+	blob, size, err := c.src.GetBlob(c.srcRepo, desc.Digest.String())
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	if desc.Size > 0 {
+		size = desc.Size
+	}
+	log.Debugf("Stream blob %s (%d bytes) to %s", desc.Digest, size, c.dstRepo)
+	return c.dst.PushBlob(c.dstRepo, desc.Digest.String(), size, blob)
+}
+
+// selectEntries returns the images matching any of the platform selectors,
+// or all of them if there are no selectors.
+func selectEntries(imgs []ImageInspect, selectors []manifestlist.PlatformSpec) []ImageInspect {
+	if len(selectors) == 0 {
+		return imgs
+	}
+	var selected []ImageInspect
+	for _, img := range imgs {
+		for _, selector := range selectors {
+			if platforms.Match(selector, img.Platform) {
+				selected = append(selected, img)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// filterList returns a manifest list of the same format as m, with only the
+// entries of the given images.
+func filterList(m distribution.Manifest, imgs []ImageInspect) (distribution.Manifest, digest.Digest, error) {
+	keep := make(map[digest.Digest]bool)
+	for _, img := range imgs {
+		keep[img.Digest] = true
+	}
+
+	var list distribution.Manifest
+	switch v := m.(type) {
+	case *manifestlist.DeserializedManifestList:
+		var manifests []manifestlist.ManifestDescriptor
+		for _, desc := range v.Manifests {
+			if keep[desc.Digest] {
+				manifests = append(manifests, desc)
+			}
+		}
+		filtered, err := manifestlist.FromDescriptors(manifests)
+		if err != nil {
+			return nil, "", err
+		}
+		list = filtered
+	case *ocischema.DeserializedIndex:
+		var manifests []ocischema.Descriptor
+		for _, desc := range v.Manifests {
+			if keep[desc.Digest] {
+				manifests = append(manifests, desc)
+			}
+		}
+		filtered, err := ocischema.FromDescriptors(manifests, v.Annotations)
+		if err != nil {
+			return nil, "", err
+		}
+		list = filtered
+	default:
+		return nil, "", fmt.Errorf("unsupported manifest list %T", m)
+	}
+
+	_, payload, err := list.Payload()
+	if err != nil {
+		return nil, "", err
+	}
+	return list, digest.FromBytes(payload), nil
+}
@@ -0,0 +1,156 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestFilterList(t *testing.T) {
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}
+	index, err := ocischema.FromDescriptors([]ocischema.Descriptor{
+		{MediaType: ocischema.MediaTypeImageManifest, Digest: digest.FromString("amd64"), Platform: &amd64},
+		{MediaType: ocischema.MediaTypeImageManifest, Digest: digest.FromString("arm"), Platform: &arm},
+	}, map[string]string{"org.opencontainers.image.version": "1.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imgs := []ImageInspect{
+		{Digest: digest.FromString("amd64"), Platform: amd64},
+		{Digest: digest.FromString("arm"), Platform: arm},
+	}
+
+	selected := selectEntries(imgs, []manifestlist.PlatformSpec{{OS: "linux", Architecture: "arm"}})
+	if len(selected) != 1 || selected[0].Digest != digest.FromString("arm") {
+		t.Fatalf("selected %v, want the arm entry", selected)
+	}
+
+	m, dgst, err := filterList(index, selected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, ok := m.(*ocischema.DeserializedIndex)
+	if !ok {
+		t.Fatalf("filtered list is a %T, want an OCI index", m)
+	}
+	if len(filtered.Manifests) != 1 || filtered.Manifests[0].Digest != digest.FromString("arm") {
+		t.Errorf("filtered entries are %v, want the arm entry", filtered.Manifests)
+	}
+	if filtered.Annotations["org.opencontainers.image.version"] != "1.4" {
+		t.Errorf("annotations of the index are lost: %v", filtered.Annotations)
+	}
+	_, payload, _ := filtered.Payload()
+	if dgst != digest.FromBytes(payload) {
+		t.Errorf("digest is %s, want %s", dgst, digest.FromBytes(payload))
+	}
+}
+
+func TestCopyAllTags(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+	r.tagsPageSize = 2
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	windows := manifestlist.PlatformSpec{OS: "windows", Architecture: "amd64"}
+	layer := r.putBlob("app", schema2.MediaTypeLayer, []byte("layer"))
+	foreign := distribution.Descriptor{
+		MediaType: schema2.MediaTypeForeignLayer,
+		Size:      7,
+		Digest:    digest.FromString("foreign"),
+		URLs:      []string{"https://example.com/foreign"},
+	}
+	entries := []manifestlist.ManifestDescriptor{
+		{Descriptor: r.putImage("app", "", fakeConfig(amd64, nil), layer), Platform: amd64},
+		{Descriptor: r.putImage("app", "", fakeConfig(windows, nil), foreign, layer), Platform: windows},
+	}
+	tags := []string{"1", "1.4", "1.4-amd64", "latest"}
+	putFakeListOf(r, "app", "1", entries...)
+	putFakeListOf(r, "app", "1.4", entries...)
+	r.put("app", "1.4-amd64", mustFetch(t, r, "app", entries[0].Digest))
+	putFakeListOf(r, "app", "latest", entries[1])
+
+	// mounts are refused, so each blob is streamed once the copier checked
+	// it was not mounted anyway
+	r.refuseMounts = true
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	results, err := Copy(auth, r.Host()+"/app", r.Host()+"/mirror", &CopyOptions{AllTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(tags) {
+		t.Fatalf("copied %d tags, want %d", len(results), len(tags))
+	}
+	for i, result := range results {
+		if result.Tag != tags[i] || r.digest("mirror", tags[i]) != r.digest("app", tags[i]) {
+			t.Errorf("tag %s is not copied with its digest", tags[i])
+		}
+	}
+	if pages := r.requested("/tags/list"); len(pages) != 2 {
+		t.Errorf("tags were listed with %v, want 2 pages", pages)
+	}
+
+	want := []string{
+		"HEAD /v2/mirror/blobs/" + layer.Digest.String(),
+		"POST /v2/mirror/blobs/uploads/?mount=" + layer.Digest.String(),
+		"HEAD /v2/mirror/blobs/" + layer.Digest.String(),
+		"GET /v2/app/blobs/" + layer.Digest.String(),
+		"PUT /v2/mirror/blobs/uploads/",
+	}
+	requests := r.requested(layer.Digest.String())
+	if len(requests) < len(want) {
+		t.Fatalf("requests of the layer are %v, want %v", requests, want)
+	}
+	for i := range want {
+		if !strings.HasPrefix(requests[i], want[i]) {
+			t.Errorf("requests of the layer are %v, want %v first", requests, want)
+			break
+		}
+	}
+	// each blob is uploaded once, with a POST starting the upload then a PUT
+	uploads := r.written("/v2/mirror/blobs/uploads/")
+	if len(r.written("PUT /v2/mirror/blobs/uploads/")) != 3 {
+		t.Errorf("uploads %v, want the layer and both configurations once", uploads)
+	}
+	for i, w := range uploads {
+		if strings.HasPrefix(w, "PUT ") && (i == 0 || uploads[i-1] != "POST /v2/mirror/blobs/uploads/") {
+			t.Errorf("upload %q was not started by a POST: %v", w, uploads)
+		}
+	}
+	if !r.hasBlob("mirror", layer.Digest) {
+		t.Error("the layer was not copied")
+	}
+	if reqs := r.requested(foreign.Digest.String()); len(reqs) != 0 {
+		t.Errorf("the foreign layer was requested: %v", reqs)
+	}
+
+	// mounts are accepted, so nothing is uploaded
+	r.refuseMounts = false
+	if _, err := Copy(auth, r.Host()+"/app:1.4", r.Host()+"/mounted:1.4", &CopyOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if r.digest("mounted", "1.4") != r.digest("app", "1.4") || !r.hasBlob("mounted", layer.Digest) {
+		t.Error("1.4 is not copied to mounted")
+	}
+	if uploads := r.written("PUT /v2/mounted/blobs/uploads/"); len(uploads) != 0 {
+		t.Errorf("blobs were uploaded instead of mounted: %v", uploads)
+	}
+}
+
+// mustFetch returns a manifest stored in the fake registry.
+func mustFetch(t *testing.T, r *fakeRegistry, repo string, dgst digest.Digest) distribution.Manifest {
+	r.mu.Lock()
+	stored := r.manifests[repo+"@"+dgst.String()]
+	r.mu.Unlock()
+	m, _, err := distribution.UnmarshalManifest(stored.mediaType, stored.payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,315 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+	"github.com/sakeven/manifest/pkg/registry"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+// Diff is the difference between two images or manifest lists.
+type Diff struct {
+	A       string        `json:"a"`
+	B       string        `json:"b"`
+	DigestA digest.Digest `json:"digestA"`
+	DigestB digest.Digest `json:"digestB"`
+
+	// Added are the platforms only in B, and Removed the ones only in A.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+
+	// Changed are the platforms whose image differs between A and B.
+	Changed []ImageDiff `json:"changed,omitempty"`
+
+	// Unchanged are the platforms whose image is the same in A and B.
+	Unchanged []string `json:"unchanged,omitempty"`
+}
+
+// ImageDiff is the difference between the images of a platform.
+type ImageDiff struct {
+	Platform string        `json:"platform"`
+	DigestA  digest.Digest `json:"digestA"`
+	DigestB  digest.Digest `json:"digestB"`
+
+	// AddedLayers are the layers only in B, and RemovedLayers the ones only
+	// in A.
+	AddedLayers   []digest.Digest `json:"addedLayers,omitempty"`
+	RemovedLayers []digest.Digest `json:"removedLayers,omitempty"`
+
+	// Config are the changed fields of the image configuration.
+	Config []FieldDiff `json:"config,omitempty"`
+
+	// SizeA and SizeB are the total sizes of the layers.
+	SizeA int64 `json:"sizeA"`
+	SizeB int64 `json:"sizeB"`
+}
+
+// FieldDiff is a changed field of an image configuration, like env.PATH or
+// labels.maintainer. A is empty if the field is added, B if it is removed.
+type FieldDiff struct {
+	Field string `json:"field"`
+	A     string `json:"a,omitempty"`
+	B     string `json:"b,omitempty"`
+}
+
+// Empty returns true if A and B are the same.
+func (d *Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffImages compares two images or manifest lists. The entries of manifest
+// lists are matched by platform. Two images are always compared with each
+// other, whatever their platforms.
+func DiffImages(a *AuthInfo, imageA, imageB string) (*Diff, error) {
+	clients := newClientPool(a)
+	sideA, err := fetchDiffSide(clients, imageA)
+	if err != nil {
+		return nil, err
+	}
+	sideB, err := fetchDiffSide(clients, imageB)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &Diff{
+		A:       imageA,
+		B:       imageB,
+		DigestA: sideA.imgs[0].Digest,
+		DigestB: sideB.imgs[0].Digest,
+	}
+	entriesA, entriesB := sideA.entries(), sideB.entries()
+	if !sideA.isList() && !sideB.isList() {
+		entriesB = map[string]ImageInspect{platformKeys(sideA.imgs)[0]: sideB.imgs[0]}
+	}
+
+	for _, key := range sortedKeys(entriesA) {
+		imgA := entriesA[key]
+		imgB, ok := entriesB[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, key)
+			continue
+		}
+		if imgA.Digest == imgB.Digest {
+			diff.Unchanged = append(diff.Unchanged, key)
+			continue
+		}
+		imageDiff, err := diffImage(sideA, sideB, imgA, imgB)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", key, err)
+		}
+		imageDiff.Platform = key
+		diff.Changed = append(diff.Changed, *imageDiff)
+	}
+	for _, key := range sortedKeys(entriesB) {
+		if _, ok := entriesA[key]; !ok {
+			diff.Added = append(diff.Added, key)
+		}
+	}
+	return diff, nil
+}
+
+// diffSide is one of the images or manifest lists compared.
+type diffSide struct {
+	r    *registry.Client
+	repo string
+	imgs []ImageInspect
+}
+
+func fetchDiffSide(clients *clientPool, image string) (*diffSide, error) {
+	ref, err := reference.ParseNamed(image)
+	if err != nil {
+		return nil, err
+	}
+	r, err := clients.get(ref.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	repo, tag := Parse(ref)
+	imgs, err := Inspect(r, repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(imgs) == 0 {
+		return nil, fmt.Errorf("unsupported manifest %s", image)
+	}
+	return &diffSide{r: r, repo: repo, imgs: imgs}, nil
+}
+
+func (s *diffSide) isList() bool {
+	return IsManifestList(s.imgs[0].MediaType)
+}
+
+// entries returns the images of a side by platform. Entries sharing a
+// platform are keyed by platform and digest instead, so that none of them is
+// hidden.
+func (s *diffSide) entries() map[string]ImageInspect {
+	imgs := s.imgs
+	if s.isList() {
+		imgs = imgs[1:]
+	}
+	keys := platformKeys(imgs)
+	count := make(map[string]int)
+	for _, key := range keys {
+		count[key]++
+	}
+	entries := make(map[string]ImageInspect)
+	for i, key := range keys {
+		if count[key] > 1 {
+			key += "@" + imgs[i].Digest.String()
+		}
+		entries[key] = imgs[i]
+	}
+	return entries
+}
+
+// platformKeys returns the platform of each image. The OS build is added when
+// the image has an OS version, so that Windows images of different builds
+// are told apart, while a new revision of the same build is a change.
+func platformKeys(imgs []ImageInspect) []string {
+	keys := make([]string, len(imgs))
+	for i, img := range imgs {
+		keys[i] = platforms.Format(img.Platform)
+		if img.Platform.OSVersion != "" {
+			parts := strings.SplitN(img.Platform.OSVersion, ".", 4)
+			if len(parts) > 3 {
+				parts = parts[:3]
+			}
+			keys[i] += " " + strings.Join(parts, ".")
+		}
+	}
+	return keys
+}
+
+func sortedKeys(entries map[string]ImageInspect) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffImage compares the layers and configuration of two images.
+func diffImage(sideA, sideB *diffSide, imgA, imgB ImageInspect) (*ImageDiff, error) {
+	d := &ImageDiff{DigestA: imgA.Digest, DigestB: imgB.Digest}
+
+	layersA, layersB := imageLayers(imgA.Manifest), imageLayers(imgB.Manifest)
+	inA, inB := make(map[digest.Digest]bool), make(map[digest.Digest]bool)
+	for _, layer := range layersA {
+		inA[layer.Digest] = true
+		d.SizeA += layer.Size
+	}
+	for _, layer := range layersB {
+		inB[layer.Digest] = true
+		d.SizeB += layer.Size
+		if !inA[layer.Digest] {
+			d.AddedLayers = append(d.AddedLayers, layer.Digest)
+		}
+	}
+	for _, layer := range layersA {
+		if !inB[layer.Digest] {
+			d.RemovedLayers = append(d.RemovedLayers, layer.Digest)
+		}
+	}
+
+	configA, err := fetchImageConfig(sideA.r, sideA.repo, imgA.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	configB, err := fetchImageConfig(sideB.r, sideB.repo, imgB.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	d.Config = diffConfig(configA, configB)
+	return d, nil
+}
+
+// imageLayers returns the layers of an image manifest.
+func imageLayers(m distribution.Manifest) []distribution.Descriptor {
+	switch v := m.(type) {
+	case *schema2.DeserializedManifest:
+		return v.Layers
+	case *ocischema.DeserializedManifest:
+		layers := make([]distribution.Descriptor, len(v.Layers))
+		for i, layer := range v.Layers {
+			layers[i] = distribution.Descriptor{MediaType: layer.MediaType, Size: layer.Size, Digest: layer.Digest, URLs: layer.URLs}
+		}
+		return layers
+	}
+	return nil
+}
+
+// diffConfig compares the platform, env, labels, entrypoint and user of two
+// image configurations.
+func diffConfig(a, b *ImageConfig) []FieldDiff {
+	fields := func(c *ImageConfig) (env, labels map[string]string, entrypoint, user string) {
+		env, labels = make(map[string]string), make(map[string]string)
+		if c.Config == nil {
+			return
+		}
+		for _, kv := range c.Config.Env {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 2 {
+				env[parts[0]] = parts[1]
+			} else {
+				env[parts[0]] = ""
+			}
+		}
+		for k, v := range c.Config.Labels {
+			labels[k] = v
+		}
+		return env, labels, strings.Join(c.Config.Entrypoint, " "), c.Config.User
+	}
+	envA, labelsA, entrypointA, userA := fields(a)
+	envB, labelsB, entrypointB, userB := fields(b)
+
+	var diffs []FieldDiff
+	if platformA, platformB := platforms.Format(a.Platform()), platforms.Format(b.Platform()); platformA != platformB {
+		diffs = append(diffs, FieldDiff{Field: "platform", A: platformA, B: platformB})
+	}
+	if a.OSVersion != b.OSVersion {
+		diffs = append(diffs, FieldDiff{Field: "os.version", A: a.OSVersion, B: b.OSVersion})
+	}
+	diffs = append(diffs, diffMaps("env.", envA, envB)...)
+	diffs = append(diffs, diffMaps("labels.", labelsA, labelsB)...)
+	if entrypointA != entrypointB {
+		diffs = append(diffs, FieldDiff{Field: "entrypoint", A: entrypointA, B: entrypointB})
+	}
+	if userA != userB {
+		diffs = append(diffs, FieldDiff{Field: "user", A: userA, B: userB})
+	}
+	return diffs
+}
+
+func diffMaps(prefix string, a, b map[string]string) []FieldDiff {
+	keys := make(map[string]bool)
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var diffs []FieldDiff
+	for _, k := range sorted {
+		va, inA := a[k]
+		vb, inB := b[k]
+		if inA != inB || va != vb {
+			diffs = append(diffs, FieldDiff{Field: prefix + k, A: va, B: vb})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,66 @@
+package manifest
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestDiffConfig(t *testing.T) {
+	a, err := ParseImageConfig([]byte(`{"os":"linux","architecture":"amd64","rootfs":{"type":"layers"},"config":{
+		"Env":["PATH=/usr/bin","VERSION=1.4.1","DEBUG"],"Labels":{"maintainer":"ops","stage":"rc"},
+		"Entrypoint":["/app"],"User":"app"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ParseImageConfig([]byte(`{"os":"linux","architecture":"amd64","rootfs":{"type":"layers"},"config":{
+		"Env":["PATH=/usr/bin","VERSION=1.4.2"],"Labels":{"maintainer":"ops","commit":"abc"},
+		"Entrypoint":["/app","--serve"],"User":"app"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []FieldDiff{
+		{Field: "env.DEBUG"},
+		{Field: "env.VERSION", A: "1.4.1", B: "1.4.2"},
+		{Field: "labels.commit", B: "abc"},
+		{Field: "labels.stage", A: "rc"},
+		{Field: "entrypoint", A: "/app", B: "/app --serve"},
+	}
+	if got := diffConfig(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffConfig() = %v, want %v", got, want)
+	}
+}
+
+func TestPlatformKeys(t *testing.T) {
+	imgs := []ImageInspect{
+		{Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}},
+		{Platform: manifestlist.PlatformSpec{OS: "windows", Architecture: "amd64", OSVersion: "10.0.14393.1593"}},
+		{Platform: manifestlist.PlatformSpec{OS: "windows", Architecture: "amd64", OSVersion: "10.0.16299"}},
+	}
+	want := []string{"linux/arm/v7", "windows/amd64 10.0.14393", "windows/amd64 10.0.16299"}
+	if got := platformKeys(imgs); !reflect.DeepEqual(got, want) {
+		t.Errorf("platformKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestDiffSideEntries(t *testing.T) {
+	arm := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	first, second := digest.FromString("first"), digest.FromString("second")
+	side := &diffSide{imgs: []ImageInspect{
+		{MediaType: manifestlist.MediaTypeManifestList},
+		{Platform: arm, Digest: first},
+		{Platform: amd64, Digest: digest.FromString("amd64")},
+		{Platform: arm, Digest: second},
+	}}
+
+	want := []string{"linux/amd64", "linux/arm/v7@" + first.String(), "linux/arm/v7@" + second.String()}
+	sort.Strings(want)
+	if got := sortedKeys(side.entries()); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries() keys = %v, want %v", got, want)
+	}
+}
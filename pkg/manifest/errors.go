@@ -0,0 +1,27 @@
+package manifest
+
+import (
+	"strings"
+)
+
+// Errors aggregates the errors of several independent operations, so that
+// every failure is reported instead of only the first one.
+type Errors []error
+
+// Error joins all aggregated error messages.
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// errOrNil returns nil for an empty Errors, so that callers can return it
+// directly as an error.
+func (errs Errors) errOrNil() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
@@ -0,0 +1,379 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+var (
+	fakeManifestPath = regexp.MustCompile(`^/v2/(.+)/manifests/([^/]+)$`)
+	fakeBlobPath     = regexp.MustCompile(`^/v2/(.+)/blobs/([^/]+)$`)
+	fakeUploadPath   = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/([^/]*)$`)
+	fakeTagsPath     = regexp.MustCompile(`^/v2/(.+)/tags/list$`)
+)
+
+type fakeManifest struct {
+	mediaType string
+	payload   []byte
+}
+
+// fakeRegistry is an in-memory registry serving manifests by tag and digest,
+// and the blobs of each repository. Blobs are mounted from another repository
+// if they exist there, unless mounts are refused, and uploaded with a POST
+// then a PUT. Requests are recorded, so that tests can check what was
+// requested and written.
+type fakeRegistry struct {
+	*httptest.Server
+	t         *testing.T
+	transport http.RoundTripper
+
+	// refuseMounts makes mounts start a regular upload instead.
+	refuseMounts bool
+	// tagsPageSize paginates tag lists with Link headers, if it is not zero.
+	tagsPageSize int
+	// delay delays manifest requests, to check how many run concurrently.
+	delay time.Duration
+	// onWrite is called with the request line of every write before it is
+	// handled, with the registry locked.
+	onWrite func(line string)
+
+	mu          sync.Mutex
+	manifests   map[string]*fakeManifest
+	blobs       map[string][]byte
+	requests    []string
+	writes      []string
+	uploads     int
+	inflight    int
+	maxInflight int
+}
+
+// newFakeRegistry starts a fake registry, and makes registry clients trust it
+// until it is closed.
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	r := &fakeRegistry{t: t, manifests: make(map[string]*fakeManifest), blobs: make(map[string][]byte)}
+	r.Server = httptest.NewTLSServer(http.HandlerFunc(r.serve))
+	r.transport = http.DefaultTransport
+	http.DefaultTransport = r.Server.Client().Transport
+	return r
+}
+
+// Close stops the registry and restores the transport of registry clients.
+func (r *fakeRegistry) Close() {
+	http.DefaultTransport = r.transport
+	r.Server.Close()
+}
+
+// Host returns the host of the registry, to use in references.
+func (r *fakeRegistry) Host() string {
+	return strings.TrimPrefix(r.URL, "https://")
+}
+
+// put stores a manifest under a tag of a repository, and by digest.
+func (r *fakeRegistry) put(repo, tag string, m distribution.Manifest) digest.Digest {
+	mediaType, payload, err := m.Payload()
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	dgst := digest.FromBytes(payload)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored := &fakeManifest{mediaType: mediaType, payload: payload}
+	r.manifests[repo+"@"+dgst.String()] = stored
+	if tag != "" {
+		r.manifests[repo+":"+tag] = stored
+	}
+	return dgst
+}
+
+// putBlob stores a blob in a repository, and returns its descriptor.
+func (r *fakeRegistry) putBlob(repo, mediaType string, content []byte) distribution.Descriptor {
+	dgst := digest.FromBytes(content)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blobs[repo+"@"+dgst.String()] = content
+	return distribution.Descriptor{MediaType: mediaType, Size: int64(len(content)), Digest: dgst}
+}
+
+// hasBlob returns true if a blob is stored in a repository.
+func (r *fakeRegistry) hasBlob(repo string, dgst digest.Digest) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.blobs[repo+"@"+dgst.String()]
+	return ok
+}
+
+// digest returns the digest a tag points to, or an empty digest.
+func (r *fakeRegistry) digest(repo, tag string) digest.Digest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.manifests[repo+":"+tag]; ok {
+		return digest.FromBytes(m.payload)
+	}
+	return ""
+}
+
+// hasManifest returns true if a manifest is stored in a repository.
+func (r *fakeRegistry) hasManifest(repo string, dgst digest.Digest) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.manifests[repo+"@"+dgst.String()]
+	return ok
+}
+
+// written returns the writes whose request line contains s.
+func (r *fakeRegistry) written(s string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return filterRequests(r.writes, s)
+}
+
+// requested returns the requests whose request line contains s, in order.
+func (r *fakeRegistry) requested(s string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return filterRequests(r.requests, s)
+}
+
+func filterRequests(requests []string, s string) []string {
+	var matching []string
+	for _, req := range requests {
+		if strings.Contains(req, s) {
+			matching = append(matching, req)
+		}
+	}
+	return matching
+}
+
+func (r *fakeRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if r.delay > 0 && fakeManifestPath.MatchString(req.URL.Path) {
+		r.mu.Lock()
+		r.inflight++
+		if r.inflight > r.maxInflight {
+			r.maxInflight = r.inflight
+		}
+		r.mu.Unlock()
+		time.Sleep(r.delay)
+		defer func() {
+			r.mu.Lock()
+			r.inflight--
+			r.mu.Unlock()
+		}()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if req.URL.Path == "/token" {
+		w.Write([]byte(`{"token":"fake"}`))
+		return
+	}
+	if req.Header.Get("Authorization") == "" {
+		w.Header().Set("Www-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="fake"`, r.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	line := req.Method + " " + req.URL.Path
+	if query, _ := url.QueryUnescape(req.URL.RawQuery); query != "" {
+		line += "?" + query
+	}
+	r.requests = append(r.requests, line)
+	if req.Method != "GET" && req.Method != "HEAD" {
+		r.writes = append(r.writes, line)
+		if r.onWrite != nil {
+			r.onWrite(line)
+		}
+	}
+
+	if m := fakeManifestPath.FindStringSubmatch(req.URL.Path); m != nil {
+		r.serveManifest(w, req, m[1], m[2])
+		return
+	}
+	if m := fakeUploadPath.FindStringSubmatch(req.URL.Path); m != nil {
+		r.serveUpload(w, req, m[1], m[2])
+		return
+	}
+	if m := fakeBlobPath.FindStringSubmatch(req.URL.Path); m != nil {
+		blob, ok := r.blobs[m[1]+"@"+m[2]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(blob)))
+		w.Header().Set("Docker-Content-Digest", m[2])
+		w.WriteHeader(http.StatusOK)
+		if req.Method == "GET" {
+			w.Write(blob)
+		}
+		return
+	}
+	if m := fakeTagsPath.FindStringSubmatch(req.URL.Path); m != nil {
+		r.serveTags(w, req, m[1])
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (r *fakeRegistry) serveManifest(w http.ResponseWriter, req *http.Request, repo, reference string) {
+	key := repo + ":" + reference
+	if strings.HasPrefix(reference, "sha256:") {
+		key = repo + "@" + reference
+	}
+	switch req.Method {
+	case "GET", "HEAD":
+		stored, ok := r.manifests[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", stored.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(stored.payload).String())
+		if req.Method == "GET" {
+			w.Write(stored.payload)
+		}
+	case "PUT":
+		payload, _ := ioutil.ReadAll(req.Body)
+		dgst := digest.FromBytes(payload)
+		stored := &fakeManifest{mediaType: req.Header.Get("Content-Type"), payload: payload}
+		r.manifests[key] = stored
+		r.manifests[repo+"@"+dgst.String()] = stored
+		w.Header().Set("Docker-Content-Digest", dgst.String())
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// serveUpload mounts a blob, or starts or completes a monolithic upload.
+func (r *fakeRegistry) serveUpload(w http.ResponseWriter, req *http.Request, repo, id string) {
+	query := req.URL.Query()
+	switch {
+	case req.Method == "POST" && query.Get("mount") != "":
+		dgst, from := query.Get("mount"), query.Get("from")
+		if blob, ok := r.blobs[from+"@"+dgst]; ok && !r.refuseMounts {
+			r.blobs[repo+"@"+dgst] = blob
+			w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", repo, dgst))
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		fallthrough
+	case req.Method == "POST":
+		r.uploads++
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%d", repo, r.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == "PUT" && id != "":
+		content, _ := ioutil.ReadAll(req.Body)
+		dgst := query.Get("digest")
+		if digest.FromBytes(content).String() != dgst {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.blobs[repo+"@"+dgst] = content
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// serveTags lists the tags of a repository, a page at a time if tagsPageSize
+// is set.
+func (r *fakeRegistry) serveTags(w http.ResponseWriter, req *http.Request, repo string) {
+	tags := []string{}
+	for key := range r.manifests {
+		if strings.HasPrefix(key, repo+":") {
+			tags = append(tags, strings.TrimPrefix(key, repo+":"))
+		}
+	}
+	sort.Strings(tags)
+
+	if last := req.URL.Query().Get("last"); last != "" {
+		i := sort.SearchStrings(tags, last)
+		if i < len(tags) && tags[i] == last {
+			i++
+		}
+		tags = tags[i:]
+	}
+	n := r.tagsPageSize
+	if v := req.URL.Query().Get("n"); v != "" {
+		n, _ = strconv.Atoi(v)
+	}
+	if n > 0 && len(tags) > n {
+		tags = tags[:n]
+		w.Header().Set("Link", fmt.Sprintf(`</v2/%s/tags/list?n=%d&last=%s>; rel="next"`, repo, n, tags[n-1]))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"name": repo, "tags": tags})
+}
+
+// fakeConfig returns the image configuration of a platform, with labels.
+func fakeConfig(p manifestlist.PlatformSpec, labels map[string]string) []byte {
+	config, err := json.Marshal(map[string]interface{}{
+		"os":           p.OS,
+		"architecture": p.Architecture,
+		"variant":      p.Variant,
+		"os.version":   p.OSVersion,
+		"config":       map[string]interface{}{"Labels": labels},
+		"rootfs":       map[string]interface{}{"type": "layers", "diff_ids": []string{}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
+
+// putImage stores a docker image manifest with a configuration and layers
+// under a tag if it is not empty, and returns its descriptor. The layers must
+// have been stored with putBlob, unless they are meant to be missing.
+func (r *fakeRegistry) putImage(repo, tag string, config []byte, layers ...distribution.Descriptor) distribution.Descriptor {
+	img, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    r.putBlob(repo, schema2.MediaTypeImageConfig, config),
+		Layers:    layers,
+	})
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	_, payload, _ := img.Payload()
+	return distribution.Descriptor{MediaType: schema2.MediaTypeManifest, Size: int64(len(payload)), Digest: r.put(repo, tag, img)}
+}
+
+// putFakeImage stores an image of a platform, with its configuration, under
+// a tag if it is not empty, and returns its descriptor.
+func putFakeImage(r *fakeRegistry, repo, tag string, p manifestlist.PlatformSpec) distribution.Descriptor {
+	return r.putImage(repo, tag, fakeConfig(p, nil))
+}
+
+// putFakeList stores images of the given platforms and a docker manifest
+// list of them under a tag, and returns the digest of the list.
+func putFakeList(r *fakeRegistry, repo, tag string, specs ...manifestlist.PlatformSpec) digest.Digest {
+	var manifests []manifestlist.ManifestDescriptor
+	for _, p := range specs {
+		manifests = append(manifests, manifestlist.ManifestDescriptor{
+			Descriptor: putFakeImage(r, repo, "", p),
+			Platform:   p,
+		})
+	}
+	return putFakeListOf(r, repo, tag, manifests...)
+}
+
+// putFakeListOf stores a docker manifest list of entries under a tag, and
+// returns its digest.
+func putFakeListOf(r *fakeRegistry, repo, tag string, entries ...manifestlist.ManifestDescriptor) digest.Digest {
+	list, err := manifestlist.FromDescriptors(entries)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return r.put(repo, tag, list)
+}
@@ -0,0 +1,66 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+// detectFormat returns the format of a manifest list of the given entries:
+// an OCI image index if all entries are OCI image manifests, and a docker
+// manifest list otherwise.
+func detectFormat(manifests []manifestlist.ManifestDescriptor) string {
+	if len(manifests) == 0 {
+		return FormatDocker
+	}
+	for _, m := range manifests {
+		if m.MediaType != ocischema.MediaTypeImageManifest {
+			return FormatDocker
+		}
+	}
+	return FormatOCI
+}
+
+// toOCIDescriptors converts manifest list entries to OCI image index entries.
+func toOCIDescriptors(manifests []manifestlist.ManifestDescriptor) []ocischema.Descriptor {
+	descs := make([]ocischema.Descriptor, len(manifests))
+	for i, m := range manifests {
+		platform := m.Platform
+		descs[i] = ocischema.Descriptor{
+			MediaType: m.MediaType,
+			Size:      m.Size,
+			Digest:    m.Digest,
+			URLs:      m.URLs,
+			Platform:  &platform,
+		}
+	}
+	return descs
+}
+
+// listEntries returns the entries of a docker manifest list or an OCI image
+// index. Entries of an index without a platform get an empty one.
+func listEntries(m distribution.Manifest) ([]manifestlist.ManifestDescriptor, error) {
+	switch v := m.(type) {
+	case *manifestlist.DeserializedManifestList:
+		return v.Manifests, nil
+	case *ocischema.DeserializedIndex:
+		manifests := make([]manifestlist.ManifestDescriptor, len(v.Manifests))
+		for i, desc := range v.Manifests {
+			manifests[i].Descriptor = distribution.Descriptor{
+				MediaType: desc.MediaType,
+				Size:      desc.Size,
+				Digest:    desc.Digest,
+				URLs:      desc.URLs,
+			}
+			if desc.Platform != nil {
+				manifests[i].Platform = *desc.Platform
+			}
+		}
+		return manifests, nil
+	}
+	mediaType, _, _ := m.Payload()
+	return nil, fmt.Errorf("manifest of type %s is not a manifest list", mediaType)
+}
@@ -1,8 +1,10 @@
 package manifest
 
 import (
+	"github.com/sakeven/manifest/pkg/ocischema"
 	"github.com/sakeven/manifest/pkg/reference"
 
+	"github.com/docker/distribution/manifest/manifestlist"
 	dreference "github.com/docker/distribution/reference"
 )
 
@@ -25,3 +27,9 @@ func isSameRepo(a, b reference.Named) bool {
 func isSameHub(a, b reference.Named) bool {
 	return a.Hostname() == b.Hostname()
 }
+
+// IsManifestList returns true if mediaType is the media type of a docker
+// manifest list or an OCI image index.
+func IsManifestList(mediaType string) bool {
+	return mediaType == manifestlist.MediaTypeManifestList || mediaType == ocischema.MediaTypeImageIndex
+}
@@ -1,6 +1,12 @@
 package manifest
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
 	"github.com/sakeven/manifest/pkg/registry"
 
 	log "github.com/Sirupsen/logrus"
@@ -21,6 +27,17 @@ type ImageInspect struct {
 	Platform   manifestlist.PlatformSpec
 	References []string
 	Manifest   distribution.Manifest
+
+	// Config is the image configuration, Labels its labels and Created its
+	// creation time, only set for images inspected directly rather than as
+	// entries of a manifest list.
+	Config  []byte
+	Labels  map[string]string
+	Created time.Time
+
+	// Annotations are the annotations of an OCI image index, or of an entry
+	// of an OCI image index, or of an OCI image manifest.
+	Annotations map[string]string
 }
 
 // Inspect get images inspect information
@@ -32,33 +49,37 @@ func Inspect(r *registry.Client, repository, tag string) ([]ImageInspect, error)
 
 	var imgs []*image.Image
 	var ms []distribution.Manifest
-	var platforms []manifestlist.PlatformSpec
+	var specs []manifestlist.PlatformSpec
+	var annotations []map[string]string
+	var (
+		rawConfig []byte
+		labels    map[string]string
+		created   time.Time
+	)
 
 	switch v := m.(type) {
 	case *schema1.SignedManifest:
-	case *schema2.DeserializedManifest:
+	case *schema2.DeserializedManifest, *ocischema.DeserializedManifest:
 		log.Debugf("%#v", v)
-		blob, err := r.PullBlob(repository, v.Config.Digest.String())
+		config, err := fetchImageConfig(r, repository, m)
 		if err != nil {
 			return nil, err
 		}
-		img, err := image.NewFromJSON(blob)
-		if err != nil {
-			return nil, err
+		imgs = append(imgs, config.Image)
+		// the variant is read from the configuration, or from its labels
+		specs = append(specs, platforms.Canonical(config.Platform()))
+		if config.Config != nil {
+			labels = config.Config.Labels
 		}
-		imgs = append(imgs, img)
-		platform := manifestlist.PlatformSpec{
-			Architecture: img.Architecture,
-			OS:           img.OS,
-			OSVersion:    img.OSVersion,
-			OSFeatures:   img.OSFeatures,
+		created, rawConfig = config.Created, config.Raw
+		if oci, ok := v.(*ocischema.DeserializedManifest); ok {
+			annotations = append(annotations, oci.Annotations)
 		}
-		platforms = append(platforms, platform)
 		ms = append(ms, m)
 	case *manifestlist.DeserializedManifestList:
 		// json.NewEncoder(os.Stdout).Encode(v)
 		ms = append(ms, v)
-		platforms = append(platforms, manifestlist.PlatformSpec{})
+		specs = append(specs, manifestlist.PlatformSpec{})
 		for _, m := range v.Manifests {
 			log.Debugf("ml digest %s", m.Digest)
 			manifest, err := r.FetchManifest(repository, m.Digest.String())
@@ -66,7 +87,7 @@ func Inspect(r *registry.Client, repository, tag string) ([]ImageInspect, error)
 				return nil, err
 			}
 			ms = append(ms, manifest)
-			platforms = append(platforms, m.Platform)
+			specs = append(specs, m.Platform)
 			// switch v := manifest.(type) {
 			// case *schema2.DeserializedManifest:
 			// 	blob, err := r.PullBlob(repository, v.Config.Digest.String())
@@ -81,12 +102,43 @@ func Inspect(r *registry.Client, repository, tag string) ([]ImageInspect, error)
 			// }
 		}
 		log.Debugf("%#v", v)
+	case *ocischema.DeserializedIndex:
+		ms = append(ms, v)
+		specs = append(specs, manifestlist.PlatformSpec{})
+		annotations = append(annotations, v.Annotations)
+		for _, m := range v.Manifests {
+			log.Debugf("index digest %s", m.Digest)
+			manifest, err := r.FetchManifest(repository, m.Digest.String())
+			if err != nil {
+				return nil, err
+			}
+			ms = append(ms, manifest)
+			var platform manifestlist.PlatformSpec
+			if m.Platform != nil {
+				platform = *m.Platform
+			}
+			specs = append(specs, platform)
+			annotations = append(annotations, m.Annotations)
+		}
+		log.Debugf("%#v", v)
 	}
 
-	return populate(platforms, tag, ms)
+	imgInspect, err := populate(specs, tag, ms)
+	if err != nil {
+		return nil, err
+	}
+	for i := range annotations {
+		imgInspect[i].Annotations = annotations[i]
+	}
+	if len(imgInspect) == 1 {
+		imgInspect[0].Labels = labels
+		imgInspect[0].Created = created
+		imgInspect[0].Config = rawConfig
+	}
+	return imgInspect, nil
 }
 
-func populate(platforms []manifestlist.PlatformSpec, tag string, ms []distribution.Manifest) ([]ImageInspect, error) {
+func populate(specs []manifestlist.PlatformSpec, tag string, ms []distribution.Manifest) ([]ImageInspect, error) {
 	imgInspect := make([]ImageInspect, len(ms))
 	for i, m := range ms {
 		mediaType, payload, err := m.Payload()
@@ -98,10 +150,102 @@ func populate(platforms []manifestlist.PlatformSpec, tag string, ms []distributi
 			MediaType: mediaType,
 			Tag:       tag,
 			Digest:    digest.FromBytes(payload),
-			Platform:  platforms[i],
+			Platform:  specs[i],
 			Manifest:  m,
 		}
+		// the blobs of an image manifest, which must be available in the
+		// repository of a manifest list referencing it; foreign layers are
+		// pulled from their URLs, so they cannot be mounted
+		if !IsManifestList(mediaType) {
+			for _, ref := range m.References() {
+				if IsForeignLayer(ref) {
+					log.Debugf("Skip foreign layer %s", ref.Digest)
+					continue
+				}
+				imgInspect[i].References = append(imgInspect[i].References, ref.Digest.String())
+			}
+		}
 	}
 
 	return imgInspect, nil
 }
+
+// IsForeignLayer returns true if a layer is not stored in registries, like the
+// base layers of Windows images, which are pulled from their URLs instead.
+func IsForeignLayer(desc distribution.Descriptor) bool {
+	return len(desc.URLs) > 0 ||
+		desc.MediaType == schema2.MediaTypeForeignLayer ||
+		strings.Contains(desc.MediaType, ".nondistributable.")
+}
+
+// ForeignLayers returns the foreign layers of an image manifest.
+func ForeignLayers(m distribution.Manifest) []distribution.Descriptor {
+	var foreign []distribution.Descriptor
+	for _, layer := range imageLayers(m) {
+		if IsForeignLayer(layer) {
+			foreign = append(foreign, layer)
+		}
+	}
+	return foreign
+}
+
+// ResolvePlatform returns the entry of a manifest list which a host of the
+// given platform runs best, using the matching rules of the platforms
+// package: an arm64 host falls back to arm/v8 then older arm variants when
+// the list has no arm64 entry.
+func ResolvePlatform(list distribution.Manifest, platform manifestlist.PlatformSpec) (manifestlist.ManifestDescriptor, error) {
+	entries, err := listEntries(list)
+	if err != nil {
+		return manifestlist.ManifestDescriptor{}, err
+	}
+	candidates := make([]manifestlist.PlatformSpec, len(entries))
+	available := make([]string, len(entries))
+	for i, entry := range entries {
+		candidates[i] = entry.Platform
+		available[i] = platforms.Format(entry.Platform)
+	}
+	i := platforms.BestMatch(platform, candidates)
+	if i < 0 {
+		return manifestlist.ManifestDescriptor{}, fmt.Errorf("no entry matches platform %s, the manifest list has %s",
+			platforms.Format(platform), strings.Join(available, ", "))
+	}
+	return entries[i], nil
+}
+
+// InspectPlatform inspects the image of a platform, as if it was referenced
+// directly: the entry chosen by ResolvePlatform if tag is a manifest list, or
+// the image of tag itself if its platform matches.
+func InspectPlatform(r *registry.Client, repository, tag string, platform manifestlist.PlatformSpec) (ImageInspect, error) {
+	m, err := r.FetchManifest(repository, tag)
+	if err != nil {
+		return ImageInspect{}, err
+	}
+	mediaType, _, err := m.Payload()
+	if err != nil {
+		return ImageInspect{}, err
+	}
+
+	ref := tag
+	if IsManifestList(mediaType) {
+		entry, err := ResolvePlatform(m, platform)
+		if err != nil {
+			return ImageInspect{}, err
+		}
+		log.Debugf("Platform %s of %s resolves to %s (%s)", platforms.Format(platform), tag, entry.Digest, platforms.Format(entry.Platform))
+		ref = entry.Digest.String()
+	}
+
+	imgs, err := Inspect(r, repository, ref)
+	if err != nil {
+		return ImageInspect{}, err
+	}
+	if len(imgs) != 1 {
+		return ImageInspect{}, fmt.Errorf("unsupported manifest %s", ref)
+	}
+	img := imgs[0]
+	if ref == tag && platforms.BestMatch(platform, []manifestlist.PlatformSpec{img.Platform}) < 0 {
+		return ImageInspect{}, fmt.Errorf("image of platform %s does not match platform %s", platforms.Format(img.Platform), platforms.Format(platform))
+	}
+	img.Tag = tag
+	return img, nil
+}
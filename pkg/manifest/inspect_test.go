@@ -4,8 +4,14 @@ import (
 	"os"
 	"testing"
 
+	"github.com/sakeven/manifest/pkg/platforms"
 	"github.com/sakeven/manifest/pkg/registry"
 	// log "github.com/Sirupsen/logrus"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
 )
 
 func TestImage(t *testing.T) {
@@ -21,3 +27,74 @@ func TestImage(t *testing.T) {
 
 	// time.Sleep(time.Second)
 }
+
+func TestResolvePlatform(t *testing.T) {
+	var descs []manifestlist.ManifestDescriptor
+	for _, s := range []string{"linux/amd64", "linux/arm/v6", "linux/arm/v7", "windows/amd64"} {
+		p, _ := platforms.Parse(s)
+		descs = append(descs, manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{MediaType: schema2.MediaTypeManifest, Digest: digest.FromString(s), Size: 1},
+			Platform:   p,
+		})
+	}
+	list, err := manifestlist.FromDescriptors(descs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		"linux/amd64":   "linux/amd64",
+		"linux/x86_64":  "linux/amd64",
+		"linux/arm64":   "linux/arm/v7",
+		"linux/arm/v6":  "linux/arm/v6",
+		"windows/amd64": "windows/amd64",
+	}
+	for s, want := range tests {
+		p, _ := platforms.Parse(s)
+		entry, err := ResolvePlatform(list, p)
+		if err != nil {
+			t.Errorf("ResolvePlatform(%s) failed: %s", s, err)
+			continue
+		}
+		if entry.Digest != digest.FromString(want) {
+			t.Errorf("ResolvePlatform(%s) = %s, want %s", s, platforms.Format(entry.Platform), want)
+		}
+	}
+
+	p, _ := platforms.Parse("linux/s390x")
+	if _, err := ResolvePlatform(list, p); err == nil {
+		t.Errorf("ResolvePlatform(linux/s390x) did not fail")
+	}
+}
+
+func TestForeignLayers(t *testing.T) {
+	m, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config:    distribution.Descriptor{MediaType: schema2.MediaTypeImageConfig, Digest: digest.FromString("config"), Size: 1},
+		Layers: []distribution.Descriptor{
+			{MediaType: schema2.MediaTypeForeignLayer, Digest: digest.FromString("base"), Size: 1, URLs: []string{"https://example.com/base"}},
+			{MediaType: schema2.MediaTypeLayer, Digest: digest.FromString("app"), Size: 1},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	foreign := ForeignLayers(m)
+	if len(foreign) != 1 || foreign[0].Digest != digest.FromString("base") || foreign[0].URLs[0] != "https://example.com/base" {
+		t.Errorf("ForeignLayers() = %v, want the base layer", foreign)
+	}
+
+	imgs, err := populate([]manifestlist.PlatformSpec{{OS: "windows", Architecture: "amd64"}}, "ltsc", []distribution.Manifest{m})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range imgs[0].References {
+		if ref == digest.FromString("base").String() {
+			t.Errorf("foreign layer is in the references to mount")
+		}
+	}
+	if len(imgs[0].References) != 2 {
+		t.Errorf("references are %v, want the config and the app layer", imgs[0].References)
+	}
+}
@@ -0,0 +1,343 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+// Severity is the severity of a lint rule.
+type Severity string
+
+// Severities of lint rules.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityOff     Severity = "off"
+)
+
+// UnmarshalJSON reads a severity from a string. false is read as off, since
+// an unquoted off is a boolean in YAML.
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	if string(data) == "false" {
+		*s = SeverityOff
+		return nil
+	}
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid severity %s, expected error, warning or off", data)
+	}
+	*s = Severity(v)
+	return nil
+}
+
+// Rule is a lint rule of manifest lists.
+type Rule struct {
+	ID          string
+	Severity    Severity
+	Description string
+
+	check func(t *lintTarget, cfg *LintConfig) []string
+}
+
+// Rules are the lint rules, with their default severity.
+var Rules = []Rule{
+	{"duplicate-platform", SeverityError, "several entries have the same platform", lintDuplicatePlatforms},
+	{"arm-variant", SeverityWarning, "arm entries have no variant", lintArmVariant},
+	{"windows-os-version", SeverityWarning, "Windows entries have no os.version", lintWindowsOSVersion},
+	{"media-type-mismatch", SeverityError, "the media type of an entry differs from the one of its manifest", lintMediaTypes},
+	{"foreign-layers", SeverityWarning, "images which are not Windows images have foreign layers", lintForeignLayers},
+	{"mixed-media-types", SeverityWarning, "docker and OCI media types are mixed in one manifest list", lintMixedMediaTypes},
+	{"required-annotations", SeverityError, "the manifest list lacks annotations required by the rules file", lintRequiredAnnotations},
+}
+
+// LintConfig configures the lint rules, read from a YAML or JSON rules file.
+type LintConfig struct {
+	// Rules overrides the severity of rules by ID, to error, warning or off.
+	Rules map[string]Severity `json:"rules,omitempty"`
+
+	// RequiredAnnotations are the annotations the manifest list must have.
+	RequiredAnnotations []string `json:"requiredAnnotations,omitempty"`
+}
+
+// Finding is a problem found by a lint rule.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// LoadLintConfig reads a rules file. An empty path gives the default rules.
+func LoadLintConfig(path string) (*LintConfig, error) {
+	cfg := new(LintConfig)
+	if path == "" {
+		return cfg, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = decodeYAML(content, cfg); err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the rule IDs and severities of a lint config.
+func (cfg *LintConfig) Validate() error {
+	var errs Errors
+	for id, severity := range cfg.Rules {
+		if findRule(id) == nil {
+			errs = append(errs, fmt.Errorf("rules: unknown rule %q", id))
+		}
+		switch severity {
+		case SeverityError, SeverityWarning, SeverityOff:
+		default:
+			errs = append(errs, fmt.Errorf("rules: invalid severity %q of rule %s, expected error, warning or off", severity, id))
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errs.errOrNil()
+}
+
+// severity returns the severity of a rule, as configured or by default.
+func (cfg *LintConfig) severity(rule Rule) Severity {
+	if severity, ok := cfg.Rules[rule.ID]; ok {
+		return severity
+	}
+	return rule.Severity
+}
+
+func findRule(id string) *Rule {
+	for i := range Rules {
+		if Rules[i].ID == id {
+			return &Rules[i]
+		}
+	}
+	return nil
+}
+
+// HasErrors returns true if any finding has the error severity.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// lintEntry is an entry of a linted manifest list.
+type lintEntry struct {
+	ocischema.Descriptor
+
+	// Manifest is the manifest of the entry, or nil if it is not known.
+	Manifest distribution.Manifest
+}
+
+// lintTarget is a linted manifest list.
+type lintTarget struct {
+	MediaType   string
+	Annotations map[string]string
+	Entries     []lintEntry
+}
+
+func newLintTarget(list distribution.Manifest, manifests map[digest.Digest]distribution.Manifest) (*lintTarget, error) {
+	t := new(lintTarget)
+	var descs []ocischema.Descriptor
+	switch v := list.(type) {
+	case *manifestlist.DeserializedManifestList:
+		t.MediaType = v.MediaType
+		descs = toOCIDescriptors(v.Manifests)
+	case *ocischema.DeserializedIndex:
+		t.MediaType, t.Annotations = v.MediaType, v.Annotations
+		descs = v.Manifests
+	default:
+		mediaType, _, _ := list.Payload()
+		return nil, fmt.Errorf("manifest of type %s is not a manifest list", mediaType)
+	}
+	for _, desc := range descs {
+		if desc.Platform == nil {
+			desc.Platform = &manifestlist.PlatformSpec{}
+		}
+		t.Entries = append(t.Entries, lintEntry{Descriptor: desc, Manifest: manifests[desc.Digest]})
+	}
+	return t, nil
+}
+
+// lint runs every rule which is not off on a manifest list.
+func lint(t *lintTarget, cfg *LintConfig) []Finding {
+	var findings []Finding
+	for _, rule := range Rules {
+		severity := cfg.severity(rule)
+		if severity == SeverityOff {
+			continue
+		}
+		for _, message := range rule.check(t, cfg) {
+			findings = append(findings, Finding{Rule: rule.ID, Severity: severity, Message: message})
+		}
+	}
+	return findings
+}
+
+// Lint runs the lint rules on the manifest list of a plan.
+func (p *Plan) Lint(cfg *LintConfig) ([]Finding, error) {
+	manifests := make(map[digest.Digest]distribution.Manifest)
+	for _, img := range p.images {
+		manifests[img.Digest] = img.Manifest
+	}
+	t, err := newLintTarget(p.List, manifests)
+	if err != nil {
+		return nil, err
+	}
+	return lint(t, cfg), nil
+}
+
+// LintImage runs the lint rules on a manifest list of a registry.
+func LintImage(a *AuthInfo, listImage string, cfg *LintConfig) ([]Finding, error) {
+	ref, err := reference.ParseNamed(listImage)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newClientPool(a).get(ref.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	repo, tag := Parse(ref)
+	imgs, err := Inspect(r, repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(imgs) == 0 || !IsManifestList(imgs[0].MediaType) {
+		return nil, fmt.Errorf("%s is not a manifest list", listImage)
+	}
+	manifests := make(map[digest.Digest]distribution.Manifest)
+	for _, img := range imgs[1:] {
+		manifests[img.Digest] = img.Manifest
+	}
+	t, err := newLintTarget(imgs[0].Manifest, manifests)
+	if err != nil {
+		return nil, err
+	}
+	return lint(t, cfg), nil
+}
+
+func lintDuplicatePlatforms(t *lintTarget, cfg *LintConfig) []string {
+	var (
+		seen  []manifestlist.PlatformSpec
+		count = make(map[string]int)
+	)
+	for _, entry := range t.Entries {
+		key := samePlatformKey(*entry.Platform)
+		if count[key] == 0 {
+			seen = append(seen, *entry.Platform)
+		}
+		count[key]++
+	}
+
+	var messages []string
+	for _, p := range seen {
+		n := count[samePlatformKey(p)]
+		if n < 2 {
+			continue
+		}
+		platform := platforms.Format(p)
+		if p.OSVersion != "" {
+			platform += " " + p.OSVersion
+		}
+		messages = append(messages, fmt.Sprintf("platform %s has %d entries", platform, n))
+	}
+	return messages
+}
+
+func lintArmVariant(t *lintTarget, cfg *LintConfig) []string {
+	var messages []string
+	for _, entry := range t.Entries {
+		if entry.Platform.Architecture == "arm" && entry.Platform.Variant == "" {
+			messages = append(messages, fmt.Sprintf("entry %s (%s) has no variant, like v6 or v7", platforms.Format(*entry.Platform), entry.Digest))
+		}
+	}
+	return messages
+}
+
+func lintWindowsOSVersion(t *lintTarget, cfg *LintConfig) []string {
+	var messages []string
+	for _, entry := range t.Entries {
+		if entry.Platform.OS == "windows" && entry.Platform.OSVersion == "" {
+			messages = append(messages, fmt.Sprintf("entry %s (%s) has no os.version", platforms.Format(*entry.Platform), entry.Digest))
+		}
+	}
+	return messages
+}
+
+func lintMediaTypes(t *lintTarget, cfg *LintConfig) []string {
+	var messages []string
+	for _, entry := range t.Entries {
+		if entry.Manifest == nil {
+			continue
+		}
+		if mediaType, _, _ := entry.Manifest.Payload(); mediaType != entry.MediaType {
+			messages = append(messages, fmt.Sprintf("entry %s (%s) has media type %s, but its manifest is %s",
+				platforms.Format(*entry.Platform), entry.Digest, entry.MediaType, mediaType))
+		}
+	}
+	return messages
+}
+
+func lintForeignLayers(t *lintTarget, cfg *LintConfig) []string {
+	var messages []string
+	for _, entry := range t.Entries {
+		if entry.Manifest == nil || entry.Platform.OS == "windows" {
+			continue
+		}
+		for _, layer := range imageLayers(entry.Manifest) {
+			if IsForeignLayer(layer) {
+				messages = append(messages, fmt.Sprintf("entry %s (%s) has the foreign layer %s",
+					platforms.Format(*entry.Platform), entry.Digest, layer.Digest))
+			}
+		}
+	}
+	return messages
+}
+
+func lintMixedMediaTypes(t *lintTarget, cfg *LintConfig) []string {
+	family := func(mediaType string) string {
+		if strings.HasPrefix(mediaType, "application/vnd.oci.") {
+			return "OCI"
+		}
+		return "docker"
+	}
+	listFamily := family(t.MediaType)
+	var messages []string
+	for _, entry := range t.Entries {
+		if f := family(entry.MediaType); f != listFamily {
+			messages = append(messages, fmt.Sprintf("entry %s (%s) is a %s manifest in a %s manifest list",
+				platforms.Format(*entry.Platform), entry.Digest, f, listFamily))
+		}
+	}
+	return messages
+}
+
+func lintRequiredAnnotations(t *lintTarget, cfg *LintConfig) []string {
+	var messages []string
+	for _, key := range cfg.RequiredAnnotations {
+		if t.Annotations[key] == "" {
+			messages = append(messages, fmt.Sprintf("annotation %s is missing", key))
+		}
+	}
+	return messages
+}
@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+func lintTestEntry(mediaType string, platform manifestlist.PlatformSpec) lintEntry {
+	return lintEntry{Descriptor: ocischema.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromString(platforms.Format(platform) + platform.OSVersion),
+		Platform:  &platform,
+	}}
+}
+
+func TestLint(t *testing.T) {
+	target := &lintTarget{
+		MediaType: manifestlist.MediaTypeManifestList,
+		Entries: []lintEntry{
+			lintTestEntry(schema2.MediaTypeManifest, manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}),
+			lintTestEntry(ocischema.MediaTypeImageManifest, manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}),
+			lintTestEntry(schema2.MediaTypeManifest, manifestlist.PlatformSpec{OS: "linux", Architecture: "arm"}),
+			lintTestEntry(schema2.MediaTypeManifest, manifestlist.PlatformSpec{OS: "windows", Architecture: "amd64"}),
+		},
+	}
+
+	count := func(findings []Finding) map[string]int {
+		n := make(map[string]int)
+		for _, finding := range findings {
+			n[finding.Rule+" "+string(finding.Severity)]++
+		}
+		return n
+	}
+
+	n := count(lint(target, &LintConfig{}))
+	expected := map[string]int{
+		"duplicate-platform error":   1,
+		"arm-variant warning":        1,
+		"windows-os-version warning": 1,
+		"mixed-media-types warning":  1,
+	}
+	for key, want := range expected {
+		if n[key] != want {
+			t.Errorf("%d findings of %s, want %d", n[key], key, want)
+		}
+	}
+	if len(n) != len(expected) {
+		t.Errorf("findings %v, want %v", n, expected)
+	}
+
+	cfg := &LintConfig{
+		Rules:               map[string]Severity{"arm-variant": SeverityError, "mixed-media-types": SeverityOff},
+		RequiredAnnotations: []string{"org.opencontainers.image.source"},
+	}
+	n = count(lint(target, cfg))
+	if n["arm-variant error"] != 1 || n["mixed-media-types warning"] != 0 || n["required-annotations error"] != 1 {
+		t.Errorf("findings with rules %v", n)
+	}
+}
+
+func TestLoadLintConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		content string
+		valid   bool
+	}{
+		{"rules:\n  arm-variant: error\n  foreign-layers: \"off\"\n", true},
+		{"requiredAnnotations: [org.opencontainers.image.source]\n", true},
+		{"rules:\n  arm-variant: off\n", true},
+		{"rules:\n  no-such-rule: error\n", false},
+		{"rules:\n  arm-variant: fatal\n", false},
+		{"rule:\n  arm-variant: error\n", false},
+	}
+	for i, test := range tests {
+		path := filepath.Join(dir, "rules.yaml")
+		if err := ioutil.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadLintConfig(path)
+		if (err == nil) != test.valid {
+			t.Errorf("%d: LoadLintConfig(%q) = %v, want valid %t", i, test.content, err, test.valid)
+		}
+	}
+}
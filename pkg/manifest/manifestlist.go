@@ -2,127 +2,180 @@ package manifest
 
 import (
 	"fmt"
+	"sync"
 
+	"github.com/sakeven/manifest/pkg/platforms"
 	"github.com/sakeven/manifest/pkg/reference"
 	"github.com/sakeven/manifest/pkg/registry"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/manifestlist"
 	engineTypes "github.com/docker/docker/api/types"
 	registryTypes "github.com/docker/docker/api/types/registry"
-	"github.com/opencontainers/go-digest"
 )
 
-// we will store up a list of blobs we must ask the registry
-// to cross-mount into our target namespace
-type blobMount struct {
-	FromRepo string
-	Digest   string
+// sourceImage is a source image of a manifest list, resolved to its manifest.
+type sourceImage struct {
+	Source Source
+	Ref    reference.Named
+	Image  ImageInspect
+
+	// Missing is true if the source image is optional and does not exist.
+	Missing bool
 }
 
 // PutManifestList takes an authentication variable and pushes an image list based on the spec
-func PutManifestList(a *AuthInfo, dstImage string, srcImages ...string) (string, error) {
-	var (
-		manifestList      manifestlist.ManifestList
-		blobMountRequests []blobMount
-		manifestRequests  []distribution.Manifest
-	)
+func PutManifestList(a *AuthInfo, opts *CreateOptions, dstImage string, srcImages ...string) (string, error) {
+	srcs := make([]Source, len(srcImages))
+	for i, img := range srcImages {
+		srcs[i] = Source{Image: img}
+	}
+	plan, err := PlanManifestList(a, opts, dstImage, srcs)
+	if err != nil {
+		return "", err
+	}
+	if _, err := plan.Push(); err != nil {
+		return "", err
+	}
+	return string(plan.Digest), nil
+}
+
+// PlanManifestList resolves the source images and works out the manifest list
+// and the registry operations needed to push it, without writing anything to
+// the registry.
+func PlanManifestList(a *AuthInfo, opts *CreateOptions, dstImage string, srcImages []Source) (*Plan, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
 
 	// process the target image name reference
 	targetRef, err := reference.ParseNamed(dstImage)
 	if err != nil {
-		return "", fmt.Errorf("error parsing name for %s: %s", dstImage, err)
+		return nil, fmt.Errorf("error parsing name for %s: %s", dstImage, err)
 	}
+
+	plan := newPlan(a, opts, targetRef)
+
 	// Now create the manifest list payload by looking up the manifest schemas
 	// for the constituent images:
 	log.Info("Retrieving digests of images...")
-	for _, img := range srcImages {
-		namedRef, err := reference.ParseNamed(img)
-		if err != nil {
-			return "", err
-		}
+	srcs, err := resolveSources(plan.clients, targetRef, srcImages, opts.Jobs)
+	if err != nil {
+		return nil, err
+	}
 
-		r, err := GetHTTPClient(a, namedRef.Hostname())
-		if err != nil {
-			return "", err
-		}
+	if opts.Provenance {
+		plan.addProvenance(srcs)
+	}
+	if err := plan.setManifests(plan.addSources(srcs)); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// resolveSources inspects the source images concurrently, at most jobs at a
+// time. The result keeps the order of srcImages, and every source that failed
+// is reported in the returned error.
+func resolveSources(clients *clientPool, targetRef reference.Named, srcImages []Source, jobs int) ([]sourceImage, error) {
+	if jobs <= 0 {
+		jobs = DefaultJobs
+	}
 
-		repo, tagOrDigest := Parse(namedRef)
-		log.Debugf("%s %s", repo, tagOrDigest)
-		mfstData, err := Inspect(r, repo, tagOrDigest)
+	var (
+		srcs = make([]sourceImage, len(srcImages))
+		errs = make([]error, len(srcImages))
+		sem  = make(chan struct{}, jobs)
+		wg   sync.WaitGroup
+	)
+	for i, img := range srcImages {
+		wg.Add(1)
+		go func(i int, img Source) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			srcs[i], errs[i] = resolveSource(clients, targetRef, img)
+		}(i, img)
+	}
+	wg.Wait()
+
+	var (
+		failed   Errors
+		resolved []sourceImage
+	)
+	for i, err := range errs {
 		if err != nil {
-			return "", fmt.Errorf("inspect of image %s failed with error: %v", img, err)
+			failed = append(failed, err)
+			continue
 		}
-
-		// TODO support different hub
-		if isSameHub(namedRef, targetRef) == false {
-			return "", fmt.Errorf("cannot use source images from a different registry than the target image")
+		if srcs[i].Missing {
+			log.Warnf("Skipping image %s, it does not exist", srcs[i].Source.Image)
+			continue
 		}
+		resolved = append(resolved, srcs[i])
+	}
+	if err := failed.errOrNil(); err != nil {
+		return nil, err
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("none of the source images exist")
+	}
+	return resolved, nil
+}
 
-		if len(mfstData) > 1 {
-			// too many responses--can only happen if a manifest list was returned for the name lookup
-			return "", fmt.Errorf("manifest lists do not allow recursion")
-		}
+// samePlatformKey returns a key which is the same for platforms a runtime
+// cannot tell apart: the normalized platform and the OS version. The
+// duplicate-platform lint rule reports entries sharing a key.
+func samePlatformKey(p manifestlist.PlatformSpec) string {
+	return platforms.Format(platforms.Normalize(p)) + " " + p.OSVersion
+}
 
-		// the non-manifest list case will always have exactly one manifest response
-		imgMfst := mfstData[0]
-		manifest := manifestlist.ManifestDescriptor{
-			Platform: imgMfst.Platform,
-			Descriptor: distribution.Descriptor{
-				Digest:    imgMfst.Digest,
-				Size:      imgMfst.Size,
-				MediaType: imgMfst.MediaType,
-			},
-		}
+// resolveSource inspects a single source image of a manifest list, and applies
+// its platform override.
+func resolveSource(clients *clientPool, targetRef reference.Named, src Source) (sourceImage, error) {
+	img := src.Image
+	namedRef, err := reference.ParseNamed(img)
+	if err != nil {
+		return sourceImage{}, err
+	}
 
-		log.Debugf("Image %s is digest %s; size: %d", img, imgMfst.Digest, imgMfst.Size)
-
-		// if this image is in a different repo, we need to add the layer & config digests to the list of
-		// requested blob mounts (cross-repository push) before pushing the manifest list
-		if isSameRepo(targetRef, namedRef) == false {
-			log.Debugf("Adding manifest references of %s to blob mount requests", img)
-			for _, layer := range imgMfst.References {
-				blobMountRequests = append(blobMountRequests, blobMount{FromRepo: namedRef.FullName(), Digest: layer})
-			}
-			// also must add the manifest to be pushed in the target namespace
-			log.Debugf("Adding manifest %s -> to be pushed to %s as a manifest reference", namedRef.FullName(), namedRef.FullName())
-			manifestRequests = append(manifestRequests, imgMfst.Manifest)
-		}
-		manifestList.Manifests = append(manifestList.Manifests, manifest)
+	// TODO support different hub
+	if isSameHub(namedRef, targetRef) == false {
+		return sourceImage{}, fmt.Errorf("cannot use source image %s from a different registry than the target image", img)
 	}
 
-	deserializedManifestList, err := manifestlist.FromDescriptors(manifestList.Manifests)
+	r, err := clients.get(namedRef.Hostname())
 	if err != nil {
-		return "", fmt.Errorf("cannot deserialize manifest list: %s", err)
+		return sourceImage{}, err
 	}
 
-	httpClient, err := GetHTTPClient(a, targetRef.Hostname())
+	repo, tagOrDigest := Parse(namedRef)
+	log.Debugf("%s %s", repo, tagOrDigest)
+	mfstData, err := Inspect(r, repo, tagOrDigest)
+	if src.Optional && registry.IsNotFound(err) {
+		return sourceImage{Source: src, Ref: namedRef, Missing: true}, nil
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to setup HTTP client to repository: %s", err)
+		return sourceImage{}, fmt.Errorf("inspect of image %s failed with error: %v", img, err)
 	}
 
-	// before we push the manifest list, if we have any blob mount requests, we need
-	// to ask the registry to mount those blobs in our target so they are available
-	// as references
-	if err := mountBlobs(httpClient, targetRef, blobMountRequests); err != nil {
-		return "", fmt.Errorf("failed to mount blobs for cross-repository push: %s", err)
+	if len(mfstData) == 0 {
+		return sourceImage{}, fmt.Errorf("image %s has an unsupported manifest type", img)
 	}
-
-	// we also must push any manifests that are referenced in the manifest list into
-	// the target namespace
-	if err := pushReferences(httpClient, targetRef, manifestRequests); err != nil {
-		return "", fmt.Errorf("failed to push manifests referenced: %s", err)
+	if len(mfstData) > 1 || IsManifestList(mfstData[0].MediaType) {
+		// too many responses--can only happen if a manifest list was returned for the name lookup
+		return sourceImage{}, fmt.Errorf("image %s is a manifest list, manifest lists do not allow recursion", img)
 	}
 
-	// push final manifest
-	repo, tag := Parse(targetRef)
-	finalDigest, err := httpClient.PushManifest(repo, tag, deserializedManifestList)
-	if err != nil {
-		return "", fmt.Errorf("push manifest list failed: %s", err)
+	// the non-manifest list case will always have exactly one manifest response
+	imgMfst := mfstData[0]
+	if src.Platform != nil {
+		imgMfst.Platform = overridePlatform(imgMfst.Platform, *src.Platform)
 	}
-
-	return string(finalDigest), nil
+	return sourceImage{Source: src, Ref: namedRef, Image: imgMfst}, nil
 }
 
 // GetHTTPClient gets registry cleint
@@ -135,47 +188,6 @@ func GetHTTPClient(a *AuthInfo, endpoint string) (*registry.Client, error) {
 	return registry.NewClient(endpoint, authConfig.Username, authConfig.Password), nil
 }
 
-func pushReferences(httpClient *registry.Client, ref reference.Named, manifests []distribution.Manifest) error {
-	// for each referenced manifest object in the manifest list (that is outside of our current repo/name)
-	// we need to push by digest the manifest so that it is added as a valid reference in the current
-	// repo. This will allow us to push the manifest list properly later and have all valid references.
-
-	// first get rid of possible hostname so the target URL is constructed properly
-	name := ref.String()
-	ref, err := reference.ParseNamed(name)
-	if err != nil {
-		return fmt.Errorf("Error parsing repo/name portion of reference without hostname: %s: %v", name, err)
-	}
-	for _, manifest := range manifests {
-		_, p, err := manifest.Payload()
-		if err != nil {
-			return err
-		}
-
-		dgst := digest.FromBytes(p)
-		dgstResult, err := httpClient.PushManifest(ref.Name(), dgst.String(), manifest)
-		if err != nil {
-			return fmt.Errorf("couldn't push manifest: %v", err)
-		}
-		if dgstResult != dgst {
-			return fmt.Errorf("pushed referenced manifest received a different digest: expected %s, got %s", dgst, dgstResult)
-		}
-	}
-	return nil
-}
-
-func mountBlobs(httpClient *registry.Client, ref reference.Named, blobsRequested []blobMount) error {
-	for _, blob := range blobsRequested {
-		location, err := httpClient.MountBlob(ref.RemoteName(), blob.Digest, blob.FromRepo)
-		if err != nil {
-			log.Errorf("Mount failed %s", err)
-			return err
-		}
-		log.Debugf("Mount of blob %s succeeded, location: %q", blob.Digest, location)
-	}
-	return nil
-}
-
 // getAuthConfig gets auth config for specific registry.
 func getAuthConfig(a *AuthInfo, index *registryTypes.IndexInfo) (engineTypes.AuthConfig, error) {
 	var (
@@ -2,7 +2,14 @@ package manifest
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+
+	"github.com/docker/distribution/manifest/manifestlist"
 )
 
 func TestPutManifestList(t *testing.T) {
@@ -11,10 +18,84 @@ func TestPutManifestList(t *testing.T) {
 		Password: os.Getenv("DOCKER_PASSWORD"),
 	}
 
-	_, err := PutManifestList(auth, "daocloud.io/daocloud/gosample:latest", "daocloud.io/daocloud/gosample:linux", "daocloud.io/daocloud/gosample:windows")
+	_, err := PutManifestList(auth, nil, "daocloud.io/daocloud/gosample:latest", "daocloud.io/daocloud/gosample:linux", "daocloud.io/daocloud/gosample:windows")
 	if err != nil {
 		t.Errorf("%s", err)
 	}
 
 	// t.Errorf("%s", digest)
 }
+
+func TestSamePlatformKey(t *testing.T) {
+	same := [][2]string{{"linux/arm", "linux/arm/v7"}, {"linux/arm64", "linux/aarch64/v8"}}
+	for _, pair := range same {
+		a, _ := platforms.Parse(pair[0])
+		b, _ := platforms.Parse(pair[1])
+		if samePlatformKey(a) != samePlatformKey(b) {
+			t.Errorf("%s and %s have different keys", pair[0], pair[1])
+		}
+	}
+	a, _ := platforms.Parse("linux/arm/v6")
+	b, _ := platforms.Parse("linux/arm")
+	if samePlatformKey(a) == samePlatformKey(b) {
+		t.Errorf("linux/arm/v6 and linux/arm have the same key")
+	}
+}
+
+func TestResolveSources(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+	r.delay = 20 * time.Millisecond
+
+	archs := []string{"amd64", "arm64", "ppc64le", "s390x", "386", "riscv64"}
+	var srcs []Source
+	for _, arch := range archs {
+		putFakeImage(r, "app", arch, manifestlist.PlatformSpec{OS: "linux", Architecture: arch})
+		srcs = append(srcs, Source{Image: r.Host() + "/app:" + arch})
+	}
+	srcs = append(srcs, Source{Image: r.Host() + "/app:optional", Optional: true})
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	target, err := reference.ParseNamed(r.Host() + "/app:1.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, err := resolveSources(newClientPool(auth), target, srcs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	if r.maxInflight != 2 {
+		t.Errorf("%d sources were inspected at a time, want 2", r.maxInflight)
+	}
+	r.mu.Unlock()
+	if len(resolved) != len(archs) {
+		t.Fatalf("resolved %d sources, want %d", len(resolved), len(archs))
+	}
+	for i, src := range resolved {
+		if src.Image.Platform.Architecture != archs[i] {
+			t.Errorf("source %d is %s, want %s", i, src.Image.Platform.Architecture, archs[i])
+		}
+	}
+
+	// every failing source is reported
+	putFakeList(r, "app", "list", manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"})
+	failing := append(srcs[:1:1],
+		Source{Image: r.Host() + "/app:missing"},
+		Source{Image: r.Host() + "/app:list"},
+		Source{Image: "other.example.com/app:amd64"},
+	)
+	_, err = resolveSources(newClientPool(auth), target, failing, 2)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("resolveSources() = %v, want 3 errors", err)
+	}
+	for i, want := range []string{"app:missing", "manifest lists do not allow recursion", "different registry"} {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d is %q, want %q", i, errs[i], want)
+		}
+	}
+	if _, err := resolveSources(newClientPool(auth), target, srcs[len(srcs)-1:], 2); err == nil {
+		t.Error("resolved only missing optional sources")
+	}
+}
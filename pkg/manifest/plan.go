@@ -0,0 +1,450 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/reference"
+	"github.com/sakeven/manifest/pkg/registry"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+// we will store up a list of blobs we must ask the registry
+// to cross-mount into our target namespace
+type blobMount struct {
+	FromRepo string
+	Digest   string
+}
+
+// Plan holds a resolved manifest list and the registry operations needed to
+// push it. Nothing is written to the registry until Push is called.
+type Plan struct {
+	Target reference.Named
+	// List is either a docker manifest list or an OCI image index.
+	List   distribution.Manifest
+	Digest digest.Digest
+
+	opts       *CreateOptions
+	tags       []string
+	blobMounts []blobMount
+	references []ImageInspect
+	clients    *clientPool
+
+	// images are the source images of the entries, which are linted along
+	// with the manifest list
+	images []ImageInspect
+
+	// annotations are set on the index, and entryAnnotations on its entries
+	// by digest; only OCI image indexes have annotations
+	annotations      map[string]string
+	entryAnnotations map[digest.Digest]map[string]string
+
+	// format is the format of the list the plan starts from, kept unless the
+	// options set one
+	format string
+
+	// expected holds the digests tags which are updated in place must still
+	// point to when pushing
+	expected map[string]digest.Digest
+}
+
+func newPlan(a *AuthInfo, opts *CreateOptions, targetRef reference.Named) *Plan {
+	return &Plan{
+		Target:  targetRef,
+		opts:    opts,
+		clients: newClientPool(a),
+	}
+}
+
+// addSources returns the manifest list entries of resolved source images,
+// and plans the blob mounts and manifest pushes needed to reference them
+// from the target repository.
+func (p *Plan) addSources(srcs []sourceImage) []manifestlist.ManifestDescriptor {
+	var manifests []manifestlist.ManifestDescriptor
+	for _, src := range srcs {
+		imgMfst := src.Image
+		if len(src.Source.Annotations) > 0 {
+			if p.entryAnnotations == nil {
+				p.entryAnnotations = make(map[digest.Digest]map[string]string)
+			}
+			p.entryAnnotations[imgMfst.Digest] = mergeAnnotations(p.entryAnnotations[imgMfst.Digest], src.Source.Annotations)
+		}
+		manifest := manifestlist.ManifestDescriptor{
+			Platform: imgMfst.Platform,
+			Descriptor: distribution.Descriptor{
+				Digest:    imgMfst.Digest,
+				Size:      imgMfst.Size,
+				MediaType: imgMfst.MediaType,
+			},
+		}
+
+		log.Debugf("Image %s is digest %s; size: %d", src.Source.Image, imgMfst.Digest, imgMfst.Size)
+
+		// if this image is in a different repo, we need to add the layer & config digests to the list of
+		// requested blob mounts (cross-repository push) before pushing the manifest list
+		if isSameRepo(p.Target, src.Ref) == false {
+			log.Debugf("Adding manifest references of %s to blob mount requests", src.Source.Image)
+			for _, layer := range imgMfst.References {
+				p.addBlobMount(blobMount{FromRepo: src.Ref.RemoteName(), Digest: layer})
+			}
+			// also must add the manifest to be pushed in the target namespace
+			log.Debugf("Adding manifest %s -> to be pushed to %s as a manifest reference", src.Ref.FullName(), p.Target.FullName())
+			p.addReference(imgMfst)
+		}
+		manifests = append(manifests, manifest)
+		p.images = append(p.images, imgMfst)
+	}
+	return manifests
+}
+
+// setManifests builds the manifest list of the plan from its entries.
+func (p *Plan) setManifests(manifests []manifestlist.ManifestDescriptor) error {
+	if len(p.opts.RequirePlatforms) > 0 {
+		platforms := make([]manifestlist.PlatformSpec, len(manifests))
+		for i, m := range manifests {
+			platforms[i] = m.Platform
+		}
+		if err := CheckPlatformMatrix(p.opts.RequirePlatforms, platforms); err != nil {
+			return fmt.Errorf("refusing to create %s: %s", p.Target, err)
+		}
+	}
+	if !p.opts.KeepOrder {
+		sortManifests(manifests)
+	}
+
+	format := p.opts.Format
+	if format == "" {
+		format = p.format
+	}
+	if p.hasAnnotations() {
+		// annotations are only kept by OCI image indexes
+		if format == FormatDocker {
+			return fmt.Errorf("annotations are only supported by OCI image indexes, not by the %s format", format)
+		}
+		format = FormatOCI
+	}
+	if format == "" {
+		format = detectFormat(manifests)
+	}
+
+	var (
+		list distribution.Manifest
+		err  error
+	)
+	switch format {
+	case FormatOCI:
+		var annotations []map[string]string
+		if annotations, err = p.manifestAnnotations(manifests); err != nil {
+			return err
+		}
+		descs := toOCIDescriptors(manifests)
+		for i := range descs {
+			descs[i].Annotations = annotations[i]
+		}
+		list, err = ocischema.FromDescriptors(descs, p.indexAnnotations())
+	default:
+		list, err = manifestlist.FromDescriptors(manifests)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot deserialize manifest list: %s", err)
+	}
+	_, payload, err := list.Payload()
+	if err != nil {
+		return err
+	}
+	p.List = list
+	p.Digest = digest.FromBytes(payload)
+	return nil
+}
+
+// TagResult is the result of pushing a manifest list to one tag.
+type TagResult struct {
+	Tag    string
+	Digest digest.Digest
+	Err    error
+
+	// Unchanged is true if the tag already pointed to the manifest list, so
+	// nothing was pushed.
+	Unchanged bool
+}
+
+// AddTags adds tags the manifest list is pushed to, in the repository of
+// Target.
+func (p *Plan) AddTags(tags ...string) error {
+	for _, tag := range tags {
+		if !anchoredTagRegexp.MatchString(tag) {
+			return fmt.Errorf("%q is not a valid tag", tag)
+		}
+		p.tags = appendUnique(p.tags, tag)
+	}
+	return nil
+}
+
+// expectDigest makes pushing to tag compare-then-set: unless forced or given
+// another expected digest, the tag is only overwritten if it still points to
+// dgst. Other tags follow the usual overwrite rules.
+func (p *Plan) expectDigest(tag string, dgst digest.Digest) {
+	if p.expected == nil {
+		p.expected = make(map[string]digest.Digest)
+	}
+	p.expected[tag] = dgst
+}
+
+// tagOptions returns the options checking whether tag may be overwritten.
+// IfDigest only applies to the tag of Target, other tags follow the usual
+// overwrite rules.
+func (p *Plan) tagOptions(tag string) *CreateOptions {
+	opts := *p.opts
+	if _, target := Parse(p.Target); tag != target {
+		opts.IfDigest = ""
+	}
+	if dgst, ok := p.expected[tag]; ok && opts.IfDigest == "" && !opts.Force {
+		opts.IfDigest = dgst.String()
+	}
+	return &opts
+}
+
+// Tags returns the tags the manifest list is pushed to, starting with the
+// tag of Target.
+func (p *Plan) Tags() []string {
+	_, tag := Parse(p.Target)
+	tags := []string{tag}
+	for _, t := range p.tags {
+		tags = appendUnique(tags, t)
+	}
+	return tags
+}
+
+func (p *Plan) addBlobMount(blob blobMount) {
+	for _, b := range p.blobMounts {
+		if b == blob {
+			return
+		}
+	}
+	p.blobMounts = append(p.blobMounts, blob)
+}
+
+func (p *Plan) addReference(img ImageInspect) {
+	for _, ref := range p.references {
+		if ref.Digest == img.Digest {
+			return
+		}
+	}
+	p.references = append(p.references, img)
+}
+
+// Operations describes, in order, the registry operations Push performs.
+func (p *Plan) Operations() []string {
+	var ops []string
+	repo, _ := Parse(p.Target)
+	for _, blob := range p.blobMounts {
+		ops = append(ops, fmt.Sprintf("mount blob %s from %s into %s", blob.Digest, blob.FromRepo, repo))
+	}
+	for _, ref := range p.references {
+		ops = append(ops, fmt.Sprintf("push manifest %s (%s) to %s@%s", ref.Digest, ref.MediaType, repo, ref.Digest))
+	}
+	for _, tag := range p.Tags() {
+		ops = append(ops, fmt.Sprintf("push manifest list %s to %s:%s", p.Digest, repo, tag))
+	}
+	return ops
+}
+
+// Push performs the planned registry operations. Every tag is checked first,
+// and nothing is written if any of them may not be overwritten; only the
+// conflicting tags are returned then. The manifest list is built once and
+// then pushed to each of Tags; a failed tag does not stop the others from
+// being pushed. The result of every tag is returned, along with an error if
+// any operation failed.
+func (p *Plan) Push() ([]TagResult, error) {
+	httpClient, err := p.clients.get(p.Target.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup HTTP client to repository: %s", err)
+	}
+
+	// nothing is written unless every tag may be pushed to
+	if conflicts, err := p.CheckTags(); err != nil {
+		return conflicts, err
+	}
+
+	// before we push the manifest list, if we have any blob mount requests, we need
+	// to ask the registry to mount those blobs in our target so they are available
+	// as references
+	if err := mountBlobs(httpClient, p.Target, p.blobMounts); err != nil {
+		return nil, fmt.Errorf("failed to mount blobs for cross-repository push: %s", err)
+	}
+
+	// we also must push any manifests that are referenced in the manifest list into
+	// the target namespace
+	if err := pushReferences(httpClient, p.Target, p.references); err != nil {
+		return nil, fmt.Errorf("failed to push manifests referenced: %s", err)
+	}
+
+	// push final manifest
+	var (
+		repo, _ = Parse(p.Target)
+		results []TagResult
+		failed  Errors
+	)
+	for _, tag := range p.Tags() {
+		result := p.pushTag(httpClient, repo, tag)
+		if result.Err != nil {
+			failed = append(failed, result.Err)
+		}
+		results = append(results, result)
+	}
+	return results, failed.errOrNil()
+}
+
+// CheckTags checks, without writing anything, that each of Tags may be made
+// to point to the manifest list. The tags which may not are returned, along
+// with an error if there are any.
+func (p *Plan) CheckTags() ([]TagResult, error) {
+	httpClient, err := p.clients.get(p.Target.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup HTTP client to repository: %s", err)
+	}
+
+	var (
+		repo, _   = Parse(p.Target)
+		conflicts []TagResult
+		failed    Errors
+	)
+	for _, tag := range p.Tags() {
+		current, err := currentDigest(httpClient, repo, tag)
+		if err != nil {
+			err = fmt.Errorf("check of tag %s failed: %s", tag, err)
+		} else {
+			err = checkOverwrite(p.tagOptions(tag), tag, current, p.Digest)
+		}
+		if err != nil {
+			conflicts = append(conflicts, TagResult{Tag: tag, Err: err})
+			failed = append(failed, err)
+		}
+	}
+	return conflicts, failed.errOrNil()
+}
+
+// pushTag pushes the manifest list to a tag, unless the tag already points
+// to a different manifest and overwriting it is not allowed.
+func (p *Plan) pushTag(httpClient *registry.Client, repo, tag string) TagResult {
+	return pushTag(httpClient, p.tagOptions(tag), repo, tag, p.List, p.Digest)
+}
+
+// pushEntry pushes the manifest of an image to a tag, unless the tag already
+// points to a different manifest and overwriting it is not allowed.
+func pushEntry(httpClient *registry.Client, opts *CreateOptions, repo, tag string, img ImageInspect) TagResult {
+	return pushTag(httpClient, opts, repo, tag, img.Manifest, img.Digest)
+}
+
+func pushTag(httpClient *registry.Client, opts *CreateOptions, repo, tag string, m distribution.Manifest, dgst digest.Digest) TagResult {
+	current, err := currentDigest(httpClient, repo, tag)
+	if err != nil {
+		return TagResult{Tag: tag, Err: fmt.Errorf("check of tag %s failed: %s", tag, err)}
+	}
+	if err := checkOverwrite(opts, tag, current, dgst); err != nil {
+		return TagResult{Tag: tag, Err: err}
+	}
+	if current == dgst {
+		log.Debugf("Tag %s already points to %s", tag, current)
+		return TagResult{Tag: tag, Digest: current, Unchanged: true}
+	}
+
+	pushed, err := httpClient.PushManifest(repo, tag, m)
+	if err != nil {
+		return TagResult{Tag: tag, Err: fmt.Errorf("push manifest to tag %s failed: %s", tag, err)}
+	}
+	return TagResult{Tag: tag, Digest: pushed}
+}
+
+// currentDigest returns the digest of the manifest a tag points to, or an
+// empty digest if the tag does not exist.
+func currentDigest(httpClient *registry.Client, repo, tag string) (digest.Digest, error) {
+	desc, err := httpClient.HeadManifest(repo, tag)
+	if registry.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if desc.Digest != "" {
+		return desc.Digest, nil
+	}
+
+	// the registry did not return the digest, so compute it from the manifest
+	m, err := httpClient.FetchManifest(repo, tag)
+	if err != nil {
+		return "", err
+	}
+	_, payload, err := m.Payload()
+	if err != nil {
+		return "", err
+	}
+	return digest.FromBytes(payload), nil
+}
+
+// checkOverwrite checks whether a tag currently pointing to current may be
+// updated to point to dgst. Tags are immutable unless forced, or unless they
+// point to the digest expected by IfDigest. A tag which already points to
+// dgst is always accepted, as pushing it again changes nothing.
+func checkOverwrite(opts *CreateOptions, tag string, current, dgst digest.Digest) error {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+	if current == dgst {
+		return nil
+	}
+	if opts.IfDigest != "" {
+		if string(current) != opts.IfDigest {
+			if current == "" {
+				return fmt.Errorf("tag %s does not exist, expected it to point to %s", tag, opts.IfDigest)
+			}
+			return fmt.Errorf("tag %s points to %s, expected %s", tag, current, opts.IfDigest)
+		}
+		return nil
+	}
+	if current == "" || opts.Force {
+		return nil
+	}
+	return fmt.Errorf("tag %s already points to %s, use --force to overwrite it", tag, current)
+}
+
+func appendUnique(ss []string, s string) []string {
+	for _, v := range ss {
+		if v == s {
+			return ss
+		}
+	}
+	return append(ss, s)
+}
+
+func pushReferences(httpClient *registry.Client, ref reference.Named, imgs []ImageInspect) error {
+	// for each referenced manifest object in the manifest list (that is outside of our current repo/name)
+	// we need to push by digest the manifest so that it is added as a valid reference in the current
+	// repo. This will allow us to push the manifest list properly later and have all valid references.
+	for _, img := range imgs {
+		dgstResult, err := httpClient.PushManifest(ref.RemoteName(), img.Digest.String(), img.Manifest)
+		if err != nil {
+			return fmt.Errorf("couldn't push manifest: %v", err)
+		}
+		if dgstResult != img.Digest {
+			return fmt.Errorf("pushed referenced manifest received a different digest: expected %s, got %s", img.Digest, dgstResult)
+		}
+	}
+	return nil
+}
+
+func mountBlobs(httpClient *registry.Client, ref reference.Named, blobsRequested []blobMount) error {
+	for _, blob := range blobsRequested {
+		location, err := httpClient.MountBlob(ref.RemoteName(), blob.Digest, blob.FromRepo)
+		if err != nil {
+			log.Errorf("Mount failed %s", err)
+			return err
+		}
+		log.Debugf("Mount of blob %s succeeded, location: %q", blob.Digest, location)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestCheckOverwrite(t *testing.T) {
+	var (
+		old = digest.FromString("old")
+		new = digest.FromString("new")
+	)
+	tests := []struct {
+		opts    CreateOptions
+		current digest.Digest
+		ok      bool
+	}{
+		{CreateOptions{}, "", true},
+		{CreateOptions{}, new, true},
+		{CreateOptions{}, old, false},
+		{CreateOptions{Force: true}, old, true},
+		{CreateOptions{IfDigest: old.String()}, old, true},
+		{CreateOptions{IfDigest: old.String()}, "", false},
+		{CreateOptions{IfDigest: old.String()}, new, true},
+		{CreateOptions{IfDigest: old.String(), Force: true}, digest.FromString("other"), false},
+	}
+	for _, test := range tests {
+		err := checkOverwrite(&test.opts, "1.4", test.current, new)
+		if (err == nil) != test.ok {
+			t.Errorf("checkOverwrite(%+v, %q) = %v", test.opts, test.current, err)
+		}
+	}
+}
+
+func TestPushChecksTagsFirst(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	putFakeImage(r, "base", "amd64", amd64)
+	existing := putFakeList(r, "app", "1.4", arm64)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	srcs := []Source{{Image: r.Host() + "/base:amd64"}}
+	plan, err := PlanManifestList(auth, &CreateOptions{}, r.Host()+"/app:1.4", srcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Digest == existing {
+		t.Fatal("the planned list must differ from the existing one")
+	}
+	if err := plan.AddTags("1"); err != nil {
+		t.Fatal(err)
+	}
+
+	conflicts, err := plan.CheckTags()
+	if err == nil || len(conflicts) != 1 || conflicts[0].Tag != "1.4" {
+		t.Errorf("CheckTags() = %v, %v, want a conflict on tag 1.4", conflicts, err)
+	}
+	if _, err := plan.Push(); err == nil {
+		t.Error("Push overwrote tag 1.4 without --force")
+	}
+	if len(r.writes) != 0 || r.digest("app", "1") != "" {
+		t.Errorf("refused push wrote %v", r.writes)
+	}
+
+	plan, err = PlanManifestList(auth, &CreateOptions{Force: true}, r.Host()+"/app:1.4", srcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plan.Push(); err != nil {
+		t.Fatal(err)
+	}
+	if r.digest("app", "1.4") != plan.Digest {
+		t.Errorf("forced push did not update tag 1.4")
+	}
+}
+
+func TestPushIfDigestWithExtraTag(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	putFakeImage(r, "base", "amd64", amd64)
+	existing := putFakeList(r, "app", "1.4", arm64)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	srcs := []Source{{Image: r.Host() + "/base:amd64"}}
+	plan, err := PlanManifestList(auth, &CreateOptions{IfDigest: existing.String()}, r.Host()+"/app:1.4", srcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plan.AddTags("new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plan.Push(); err != nil {
+		t.Fatalf("Push() with --if-digest and a new extra tag failed: %s", err)
+	}
+	for _, tag := range []string{"1.4", "new"} {
+		if r.digest("app", tag) != plan.Digest {
+			t.Errorf("tag %s was not updated", tag)
+		}
+	}
+
+	// an existing extra tag is not overwritten because of --if-digest
+	putFakeList(r, "app", "1.4", arm64)
+	putFakeList(r, "app", "other", arm64, amd64)
+	if err := plan.AddTags("other"); err != nil {
+		t.Fatal(err)
+	}
+	conflicts, err := plan.CheckTags()
+	if err == nil || len(conflicts) != 1 || conflicts[0].Tag != "other" {
+		t.Errorf("CheckTags() = %v, %v, want a conflict on tag other", conflicts, err)
+	}
+
+	// --if-digest still guards the target tag
+	plan, err = PlanManifestList(auth, &CreateOptions{IfDigest: plan.Digest.String()}, r.Host()+"/app:1.4", srcs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflicts, err = plan.CheckTags()
+	if err == nil || len(conflicts) != 1 || conflicts[0].Tag != "1.4" {
+		t.Errorf("CheckTags() = %v, %v, want a conflict on tag 1.4", conflicts, err)
+	}
+}
@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+// templatePlatform holds the fields a source image template can use.
+type templatePlatform struct {
+	OS        string
+	Arch      string
+	Variant   string
+	OSVersion string
+}
+
+// ExpandTemplate expands a source image text/template for each of specs. It
+// can use .OS, .Arch, .Variant and .OSVersion, e.g.
+// registry/app:1.4-{{.Arch}}{{.Variant}} gives registry/app:1.4-arm64v8 for
+// linux/arm64/v8. The platform of each source is set to the one it was
+// expanded from. If optional is true, sources which do not exist are skipped
+// instead of failing.
+func ExpandTemplate(text string, specs []manifestlist.PlatformSpec, optional bool) ([]Source, error) {
+	tmpl, err := template.New("source").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %s", err)
+	}
+
+	srcs := make([]Source, len(specs))
+	for i := range specs {
+		p := specs[i]
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, templatePlatform{
+			OS:        p.OS,
+			Arch:      p.Architecture,
+			Variant:   p.Variant,
+			OSVersion: p.OSVersion,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %s", err)
+		}
+		srcs[i] = Source{
+			Image:    buf.String(),
+			Platform: &p,
+			Optional: optional,
+		}
+	}
+	return srcs, nil
+}
+
+// CheckPlatformMatrix checks that the actual platforms are exactly the
+// required ones: every required platform must match one of actual, and every
+// one of actual must match a required platform. Both the missing and
+// unexpected platforms are reported.
+func CheckPlatformMatrix(required, actual []manifestlist.PlatformSpec) error {
+	var missing, unexpected []string
+	for _, r := range required {
+		found := false
+		for _, p := range actual {
+			if platforms.Match(r, p) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, platforms.Format(r))
+		}
+	}
+	for _, p := range actual {
+		found := false
+		for _, r := range required {
+			if platforms.Match(r, p) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unexpected = appendUnique(unexpected, platforms.Format(p))
+		}
+	}
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing platforms "+strings.Join(missing, ", "))
+	}
+	if len(unexpected) > 0 {
+		problems = append(problems, "unexpected platforms "+strings.Join(unexpected, ", "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
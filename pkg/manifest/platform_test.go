@@ -0,0 +1,62 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	specs, err := platforms.ParseAll([]string{"linux/amd64", "linux/arm64/v8", "windows/amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srcs, err := ExpandTemplate("registry/app:1.4-{{.OS}}-{{.Arch}}{{.Variant}}", specs, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"registry/app:1.4-linux-amd64",
+		"registry/app:1.4-linux-arm64v8",
+		"registry/app:1.4-windows-amd64",
+	}
+	for i, src := range srcs {
+		if src.Image != want[i] {
+			t.Errorf("expanded %s, want %s", src.Image, want[i])
+		}
+		if src.Platform.Architecture != specs[i].Architecture || src.Platform.Variant != specs[i].Variant || !src.Optional {
+			t.Errorf("unexpected source %#v", src)
+		}
+	}
+
+	// text outside of actions is kept as is
+	srcs, err = ExpandTemplate("registry/app:OS-ARCH-{{.Arch}}", specs[:1], false)
+	if err != nil || srcs[0].Image != "registry/app:OS-ARCH-amd64" {
+		t.Errorf("ExpandTemplate() = %v, %v", srcs, err)
+	}
+	if _, err := ExpandTemplate("registry/app:{{.Architecture}}", specs, false); err == nil {
+		t.Error("ExpandTemplate() accepted an unknown field")
+	}
+}
+
+func TestCheckPlatformMatrix(t *testing.T) {
+	required, _ := platforms.ParseAll([]string{"linux/amd64", "linux/arm64/v8", "linux/ppc64le"})
+	tests := []struct {
+		platforms []string
+		err       string
+	}{
+		{[]string{"linux/ppc64le", "linux/amd64", "linux/arm64/v8"}, ""},
+		{[]string{"linux/amd64", "linux/arm64/v8"}, "missing platforms linux/ppc64le"},
+		{[]string{"linux/amd64", "linux/arm64", "linux/ppc64le"}, ""},
+		{[]string{"linux/amd64", "linux/arm/v7", "linux/ppc64le"}, "missing platforms linux/arm64/v8; unexpected platforms linux/arm/v7"},
+		{[]string{"linux/amd64", "linux/arm64/v8", "linux/ppc64le", "linux/s390x"}, "unexpected platforms linux/s390x"},
+	}
+	for _, test := range tests {
+		actual, _ := platforms.ParseAll(test.platforms)
+		err := CheckPlatformMatrix(required, actual)
+		if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+			t.Errorf("CheckPlatformMatrix(%v) = %v, want %q", test.platforms, err, test.err)
+		}
+	}
+}
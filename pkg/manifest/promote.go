@@ -0,0 +1,245 @@
+package manifest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+// PromoteAnnotationPrefix is the prefix of the annotations recording the
+// policy checks of a promoted OCI image index.
+const PromoteAnnotationPrefix = "io.github.sakeven.manifest.promote."
+
+// Names of the policy checks of a promotion.
+const (
+	CheckDigest      = "digest"
+	CheckPlatforms   = "platforms"
+	CheckLabels      = "labels"
+	CheckDestination = "destination"
+)
+
+// PromoteOptions holds the policy a promotion must pass.
+type PromoteOptions struct {
+	// ExpectDigest is the digest the source must have, if set.
+	ExpectDigest string
+
+	// RequirePlatforms are platforms the source must have entries for.
+	RequirePlatforms []manifestlist.PlatformSpec
+
+	// RequireLabels are labels the configuration of every image must have.
+	RequireLabels []string
+
+	// Annotate records the checks as annotations on the promoted index. The
+	// source must be an OCI image index, and the digest of the promoted
+	// index differs from the source one.
+	Annotate bool
+
+	// Force promotes even if the destination tag points to another manifest.
+	Force bool
+}
+
+// PolicyCheck is the result of a policy check.
+type PolicyCheck struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// PromoteReport is the result of a promotion.
+type PromoteReport struct {
+	// Source is the source pinned to its digest.
+	Source      string        `json:"source"`
+	Destination string        `json:"destination"`
+	Digest      digest.Digest `json:"digest,omitempty"`
+	Checks      []PolicyCheck `json:"checks"`
+	Promoted    bool          `json:"promoted"`
+}
+
+// Promote copies src to dst by digest, only if every policy check passes. The
+// destination tag is the source one if dst has none. The report is returned
+// even if a check fails.
+func Promote(a *AuthInfo, src, dst string, opts *PromoteOptions) (*PromoteReport, error) {
+	srcRef, err := reference.ParseNamed(src)
+	if err != nil {
+		return nil, err
+	}
+	dstRef, err := reference.ParseNamed(dst)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := dstRef.(reference.Canonical); ok {
+		return nil, fmt.Errorf("destination %s must not have a digest", dst)
+	}
+	_, srcTag := Parse(srcRef)
+	_, dstTag := Parse(dstRef)
+	if reference.IsNameOnly(dstRef) {
+		dstTag = srcTag
+	}
+	if _, err := digest.Parse(dstTag); err == nil {
+		return nil, fmt.Errorf("a destination tag is needed to promote %s", src)
+	}
+
+	c, err := newCopier(newClientPool(a), srcRef, dstRef, &CopyOptions{Force: opts.Force})
+	if err != nil {
+		return nil, err
+	}
+
+	// pin the source, so that what is checked is what is promoted
+	imgs, err := Inspect(c.src, c.srcRepo, srcTag)
+	if err != nil {
+		return nil, err
+	}
+	if len(imgs) == 0 {
+		return nil, fmt.Errorf("unsupported manifest %s", src)
+	}
+	report := &PromoteReport{
+		Source:      srcRef.Name() + "@" + imgs[0].Digest.String(),
+		Destination: dstRef.Name() + ":" + dstTag,
+		Digest:      imgs[0].Digest,
+	}
+	entries := imgs
+	if IsManifestList(imgs[0].MediaType) {
+		entries = imgs[1:]
+	}
+
+	var annotated *ocischema.DeserializedIndex
+	if opts.Annotate {
+		index, ok := imgs[0].Manifest.(*ocischema.DeserializedIndex)
+		if !ok {
+			return nil, fmt.Errorf("only an OCI image index can be annotated, %s is %s", src, imgs[0].MediaType)
+		}
+		annotated = index
+	}
+
+	if opts.ExpectDigest != "" {
+		report.check(CheckDigest, checkDigest(opts.ExpectDigest, imgs[0].Digest))
+	}
+	if len(opts.RequirePlatforms) > 0 {
+		report.check(CheckPlatforms, checkPlatforms(opts.RequirePlatforms, entries))
+	}
+	if len(opts.RequireLabels) > 0 {
+		report.check(CheckLabels, c.checkLabels(opts.RequireLabels, entries))
+	}
+
+	var promoted distribution.Manifest = imgs[0].Manifest
+	if annotated != nil {
+		// the checks so far are recorded, the destination one is not since it
+		// depends on the digest of the annotated index
+		annotations := make(map[string]string)
+		for k, v := range annotated.Annotations {
+			annotations[k] = v
+		}
+		annotations[PromoteAnnotationPrefix+"source"] = report.Source
+		for _, check := range report.Checks {
+			annotations[PromoteAnnotationPrefix+check.Name] = "passed"
+		}
+		index, err := ocischema.FromDescriptors(annotated.Manifests, annotations)
+		if err != nil {
+			return nil, err
+		}
+		_, payload, err := index.Payload()
+		if err != nil {
+			return nil, err
+		}
+		promoted, report.Digest = index, digest.FromBytes(payload)
+	}
+
+	current, err := currentDigest(c.dst, c.dstRepo, dstTag)
+	if err != nil {
+		return nil, fmt.Errorf("check of tag %s failed: %s", dstTag, err)
+	}
+	report.check(CheckDestination, checkOverwrite(&CreateOptions{Force: opts.Force}, dstTag, current, report.Digest))
+
+	var failed []string
+	for _, check := range report.Checks {
+		if !check.Passed {
+			failed = append(failed, check.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return report, fmt.Errorf("%s is not promoted, policy checks failed: %s", src, strings.Join(failed, ", "))
+	}
+
+	if annotated == nil {
+		result := c.copyTag(imgs[0].Digest.String(), dstTag)
+		if result.Err != nil {
+			return report, result.Err
+		}
+		report.Promoted = true
+		return report, nil
+	}
+
+	for _, entry := range entries {
+		if err := c.copyManifest(entry, entry.Digest.String()); err != nil {
+			return report, fmt.Errorf("copy of entry %s failed: %s", platforms.Format(entry.Platform), err)
+		}
+	}
+	result := pushTag(c.dst, &CreateOptions{Force: opts.Force}, c.dstRepo, dstTag, promoted, report.Digest)
+	if result.Err != nil {
+		return report, result.Err
+	}
+	report.Promoted = true
+	return report, nil
+}
+
+// check records the result of a policy check, which passed if err is nil.
+func (r *PromoteReport) check(name string, err error) {
+	check := PolicyCheck{Name: name, Passed: err == nil}
+	if err != nil {
+		check.Detail = err.Error()
+	}
+	r.Checks = append(r.Checks, check)
+}
+
+func checkDigest(expected string, actual digest.Digest) error {
+	if _, err := digest.Parse(expected); err != nil {
+		return fmt.Errorf("invalid expected digest %q: %s", expected, err)
+	}
+	if digest.Digest(expected) != actual {
+		return fmt.Errorf("digest is %s, expected %s", actual, expected)
+	}
+	return nil
+}
+
+// checkPlatforms checks that every required platform matches one of imgs.
+func checkPlatforms(required []manifestlist.PlatformSpec, imgs []ImageInspect) error {
+	var missing []string
+	for _, platform := range required {
+		if len(selectEntries(imgs, []manifestlist.PlatformSpec{platform})) == 0 {
+			missing = append(missing, platforms.Format(platform))
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing platforms %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// checkLabels checks that the configuration of every image has the labels.
+func (c *copier) checkLabels(labels []string, imgs []ImageInspect) error {
+	var errs Errors
+	for _, img := range imgs {
+		config, err := fetchImageConfig(c.src, c.srcRepo, img.Manifest)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", platforms.Format(img.Platform), err))
+			continue
+		}
+		var missing []string
+		for _, label := range labels {
+			if config.Label(label) == "" {
+				missing = append(missing, label)
+			}
+		}
+		if len(missing) > 0 {
+			errs = append(errs, fmt.Errorf("%s: missing labels %s", platforms.Format(img.Platform), strings.Join(missing, ", ")))
+		}
+	}
+	return errs.errOrNil()
+}
@@ -0,0 +1,152 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+	"github.com/sakeven/manifest/pkg/platforms"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestCheckPlatforms(t *testing.T) {
+	imgs := []ImageInspect{
+		{Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}},
+		{Platform: manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}},
+	}
+
+	required, _ := platforms.ParseAll([]string{"linux/amd64", "linux/arm"})
+	if err := checkPlatforms(required, imgs); err != nil {
+		t.Errorf("checkPlatforms() = %s, want nil", err)
+	}
+
+	required, _ = platforms.ParseAll([]string{"linux/amd64", "linux/arm64", "linux/arm/v6"})
+	err := checkPlatforms(required, imgs)
+	if err == nil || err.Error() != "missing platforms linux/arm64, linux/arm/v6" {
+		t.Errorf("checkPlatforms() = %v, want missing linux/arm64 and linux/arm/v6", err)
+	}
+}
+
+func TestCheckDigest(t *testing.T) {
+	dgst := digest.FromString("list")
+	if err := checkDigest(dgst.String(), dgst); err != nil {
+		t.Errorf("checkDigest() = %s, want nil", err)
+	}
+	if err := checkDigest(digest.FromString("other").String(), dgst); err == nil {
+		t.Errorf("checkDigest() with another digest passed")
+	}
+	if err := checkDigest("sha256:abc", dgst); err == nil {
+		t.Errorf("checkDigest() with an invalid digest passed")
+	}
+}
+
+func TestPromote(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	const source = "org.opencontainers.image.source"
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	labeled := []manifestlist.ManifestDescriptor{
+		{Descriptor: r.putImage("staging", "", fakeConfig(amd64, map[string]string{source: "https://example.com/app"})), Platform: amd64},
+		{Descriptor: r.putImage("staging", "", fakeConfig(arm64, map[string]string{source: "https://example.com/app"})), Platform: arm64},
+	}
+	list := putFakeListOf(r, "staging", "1.4", labeled...)
+	unlabeled := putFakeList(r, "staging", "unlabeled", amd64, arm64)
+	existing := putFakeList(r, "prod", "1.4", amd64)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	failed := func(report *PromoteReport) []string {
+		var names []string
+		for _, check := range report.Checks {
+			if !check.Passed {
+				names = append(names, check.Name+": "+check.Detail)
+			}
+		}
+		return names
+	}
+
+	// the labels are missing and the destination exists
+	report, err := Promote(auth, r.Host()+"/staging:unlabeled", r.Host()+"/prod:1.4", &PromoteOptions{RequireLabels: []string{source}})
+	if err == nil || report.Promoted {
+		t.Fatal("promoted an image failing the policy")
+	}
+	want := []string{
+		"labels: linux/amd64: missing labels " + source + "; linux/arm64: missing labels " + source,
+		"destination: tag 1.4 already points to " + existing.String() + ", use --force to overwrite it",
+	}
+	if got := failed(report); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("failed checks are %q, want %q", got, want)
+	}
+	if report.Source != r.Host()+"/staging@"+unlabeled.String() {
+		t.Errorf("source is %s, want it pinned to %s", report.Source, unlabeled)
+	}
+	if len(r.writes) != 0 {
+		t.Errorf("refused promotion wrote %v", r.writes)
+	}
+
+	// the source is pinned, and copied by digest
+	report, err = Promote(auth, r.Host()+"/staging:1.4", r.Host()+"/prod:1.4", &PromoteOptions{
+		ExpectDigest:  list.String(),
+		RequireLabels: []string{source},
+		Force:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Promoted || report.Digest != list || r.digest("prod", "1.4") != list {
+		t.Errorf("promoted %s, want %s", r.digest("prod", "1.4"), list)
+	}
+	if reqs := r.requested("GET /v2/staging/manifests/" + list.String()); len(reqs) == 0 {
+		t.Error("the source was not copied by digest")
+	}
+
+	report, err = Promote(auth, r.Host()+"/staging:unlabeled", r.Host()+"/prod:2.0", &PromoteOptions{ExpectDigest: list.String()})
+	if err == nil || len(failed(report)) != 1 || report.Checks[0].Name != CheckDigest {
+		t.Errorf("promoted a source with an unexpected digest: %v", report.Checks)
+	}
+
+	// an OCI image index records the checks as annotations
+	if _, err := Promote(auth, r.Host()+"/staging:1.4", r.Host()+"/prod:1.5", &PromoteOptions{Annotate: true}); err == nil {
+		t.Error("annotated a docker manifest list")
+	}
+	descs := toOCIDescriptors(labeled)
+	index, err := ocischema.FromDescriptors(descs, map[string]string{"org.opencontainers.image.version": "1.4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexDigest := r.put("staging", "oci", index)
+	report, err = Promote(auth, r.Host()+"/staging:oci", r.Host()+"/release:oci", &PromoteOptions{
+		ExpectDigest:  indexDigest.String(),
+		RequireLabels: []string{source},
+		Annotate:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Digest == indexDigest || r.digest("release", "oci") != report.Digest {
+		t.Fatalf("release:oci is %s, want the annotated index %s", r.digest("release", "oci"), report.Digest)
+	}
+	promoted := mustFetch(t, r, "release", report.Digest).(*ocischema.DeserializedIndex)
+	wantAnnotations := map[string]string{
+		"org.opencontainers.image.version": "1.4",
+		PromoteAnnotationPrefix + "source": r.Host() + "/staging@" + indexDigest.String(),
+		PromoteAnnotationPrefix + "digest": "passed",
+		PromoteAnnotationPrefix + "labels": "passed",
+	}
+	if len(promoted.Annotations) != len(wantAnnotations) {
+		t.Errorf("annotations are %v, want %v", promoted.Annotations, wantAnnotations)
+	}
+	for k, v := range wantAnnotations {
+		if promoted.Annotations[k] != v {
+			t.Errorf("annotation %s is %q, want %q", k, promoted.Annotations[k], v)
+		}
+	}
+	for _, entry := range labeled {
+		if !r.hasManifest("release", entry.Digest) {
+			t.Errorf("entry %s was not copied", entry.Digest)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package manifest
+
+import (
+	"regexp"
+)
+
+// semverTagRegexp matches release version tags like 1.4.2 or v1.4.2.
+// Pre-release versions like 1.4.2-rc1 are not matched, as they must not
+// replace the parent version tags of a release.
+var semverTagRegexp = regexp.MustCompile(`^(v?)(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)$`)
+
+// ExpandSemverTags expands each release version tag into itself and its
+// parent version tags, e.g. 1.4.2 into 1.4.2, 1.4 and 1. Other tags are
+// kept as they are. Duplicated tags are removed.
+func ExpandSemverTags(tags []string) []string {
+	var expanded []string
+	for _, tag := range tags {
+		expanded = appendUnique(expanded, tag)
+		m := semverTagRegexp.FindStringSubmatch(tag)
+		if m == nil {
+			continue
+		}
+		prefix, major, minor := m[1], m[2], m[3]
+		expanded = appendUnique(expanded, prefix+major+"."+minor)
+		expanded = appendUnique(expanded, prefix+major)
+	}
+	return expanded
+}
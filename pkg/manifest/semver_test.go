@@ -0,0 +1,23 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandSemverTags(t *testing.T) {
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{[]string{"1.4.2"}, []string{"1.4.2", "1.4", "1"}},
+		{[]string{"v1.4.2", "latest"}, []string{"v1.4.2", "v1.4", "v1", "latest"}},
+		{[]string{"1.4.2-rc1", "1.4"}, []string{"1.4.2-rc1", "1.4"}},
+		{[]string{"1.4.2", "1.4.3"}, []string{"1.4.2", "1.4", "1", "1.4.3"}},
+	}
+	for _, test := range tests {
+		if got := ExpandSemverTags(test.tags); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ExpandSemverTags(%v) = %v, want %v", test.tags, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package manifest
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+// platformKey returns the canonical sort key of a platform: os, architecture,
+// variant and os.version.
+func platformKey(p manifestlist.PlatformSpec) []string {
+	return []string{p.OS, p.Architecture, p.Variant, p.OSVersion}
+}
+
+// sortManifests sorts the entries of a manifest list by their canonical
+// platform key, so that the same set of images always gives the same list.
+// OS versions are ordered by build, so that Windows entries go from the
+// oldest build to the newest. Entries with identical platforms are ordered
+// by digest.
+func sortManifests(manifests []manifestlist.ManifestDescriptor) {
+	sort.SliceStable(manifests, func(i, j int) bool {
+		ki, kj := platformKey(manifests[i].Platform), platformKey(manifests[j].Platform)
+		last := len(ki) - 1
+		for n := range ki[:last] {
+			if ki[n] != kj[n] {
+				return ki[n] < kj[n]
+			}
+		}
+		if c := compareOSVersions(ki[last], kj[last]); c != 0 {
+			return c < 0
+		}
+		return manifests[i].Digest < manifests[j].Digest
+	})
+}
+
+// compareOSVersions compares OS versions like 10.0.14393.1593 part by part,
+// numerically when both parts are numbers. It returns -1, 0 or 1.
+func compareOSVersions(a, b string) int {
+	pa, pb := strings.Split(a, "."), strings.Split(b, ".")
+	for n := 0; n < len(pa) && n < len(pb); n++ {
+		if pa[n] == pb[n] {
+			continue
+		}
+		na, errA := strconv.Atoi(pa[n])
+		nb, errB := strconv.Atoi(pb[n])
+		if errA == nil && errB == nil {
+			if na < nb {
+				return -1
+			}
+			return 1
+		}
+		if pa[n] < pb[n] {
+			return -1
+		}
+		return 1
+	}
+	switch {
+	case len(pa) < len(pb):
+		return -1
+	case len(pa) > len(pb):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,78 @@
+package manifest
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestSortManifests(t *testing.T) {
+	desc := func(name, os, arch, variant, osVersion string) manifestlist.ManifestDescriptor {
+		return manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{Digest: digest.FromString(name)},
+			Platform: manifestlist.PlatformSpec{
+				OS:           os,
+				Architecture: arch,
+				Variant:      variant,
+				OSVersion:    osVersion,
+			},
+		}
+	}
+
+	a := []manifestlist.ManifestDescriptor{
+		desc("a", "windows", "amd64", "", "10.0.14393.1593"),
+		desc("b", "linux", "arm", "v7", ""),
+		desc("c", "linux", "amd64", "", ""),
+		desc("d", "linux", "arm", "v6", ""),
+	}
+	b := []manifestlist.ManifestDescriptor{a[3], a[1], a[0], a[2]}
+
+	sortManifests(a)
+	sortManifests(b)
+
+	la, err := manifestlist.FromDescriptors(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb, err := manifestlist.FromDescriptors(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, pa, _ := la.Payload()
+	_, pb, _ := lb.Payload()
+	if string(pa) != string(pb) {
+		t.Errorf("payloads differ:\n%s\n%s", pa, pb)
+	}
+
+	want := []string{"c", "d", "b", "a"}
+	for i, m := range a {
+		if m.Digest != digest.FromString(want[i]) {
+			t.Errorf("entry %d is %s, want %s", i, m.Digest, want[i])
+		}
+	}
+}
+
+func TestSortManifestsWindowsBuilds(t *testing.T) {
+	desc := func(osVersion string) manifestlist.ManifestDescriptor {
+		return manifestlist.ManifestDescriptor{
+			Descriptor: distribution.Descriptor{Digest: digest.FromString(osVersion)},
+			Platform:   manifestlist.PlatformSpec{OS: "windows", Architecture: "amd64", OSVersion: osVersion},
+		}
+	}
+	manifests := []manifestlist.ManifestDescriptor{
+		desc("10.0.17763.1"),
+		desc("10.0.14393.1593"),
+		desc("10.0.9200.1"),
+		desc("10.0.14393.206"),
+	}
+	sortManifests(manifests)
+
+	want := []string{"10.0.9200.1", "10.0.14393.206", "10.0.14393.1593", "10.0.17763.1"}
+	for i, m := range manifests {
+		if m.Platform.OSVersion != want[i] {
+			t.Errorf("entry %d is %s, want %s", i, m.Platform.OSVersion, want[i])
+		}
+	}
+}
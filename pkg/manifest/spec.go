@@ -0,0 +1,203 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	dreference "github.com/docker/distribution/reference"
+	"github.com/ghodss/yaml"
+)
+
+// anchoredTagRegexp matches a valid tag and nothing else.
+var anchoredTagRegexp = regexp.MustCompile(`^` + dreference.TagRegexp.String() + `$`)
+
+// Source is a source image of a manifest list.
+type Source struct {
+	// Image is the reference of the source image.
+	Image string `json:"image"`
+
+	// Platform overrides the platform detected from the image. Only the
+	// fields that are set are overridden.
+	Platform *manifestlist.PlatformSpec `json:"platform,omitempty"`
+
+	// Annotations are set on the entry of the image, which needs an OCI image
+	// index.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Optional skips the image if it does not exist, instead of failing.
+	Optional bool `json:"-"`
+}
+
+// Spec is a declarative description of a manifest list, read from a YAML or
+// JSON file.
+type Spec struct {
+	// Image is the reference the manifest list is pushed to.
+	Image string `json:"image"`
+
+	// Tags are additional tags the manifest list is pushed to, in the
+	// repository of Image.
+	Tags []string `json:"tags,omitempty"`
+
+	// Annotations are set on the manifest list, which needs an OCI image
+	// index. Annotations given to the command win.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// RequirePlatforms are the platforms, like linux/arm64/v8, the manifest
+	// list must have, no more and no less, if set.
+	RequirePlatforms []string `json:"requirePlatforms,omitempty"`
+
+	// Manifests are the source images of the manifest list.
+	Manifests []Source `json:"manifests"`
+}
+
+// LoadSpec reads a spec from a YAML or JSON file.
+func LoadSpec(path string) (*Spec, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ParseSpec(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec %s: %s", path, err)
+	}
+	return spec, nil
+}
+
+// ParseSpec parses a YAML or JSON spec. Unknown fields are rejected.
+func ParseSpec(content []byte) (*Spec, error) {
+	spec := new(Spec)
+	if err := decodeYAML(content, spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// decodeYAML decodes YAML or JSON content into v, rejecting unknown fields.
+func decodeYAML(content []byte, v interface{}) error {
+	// YAML is a superset of JSON, so both are converted to JSON first.
+	content, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// Validate checks a spec, and reports every problem found.
+func (s *Spec) Validate() error {
+	var errs Errors
+
+	if s.Image == "" {
+		errs = append(errs, fmt.Errorf("image is required"))
+	} else if _, err := reference.ParseNamed(s.Image); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, tag := range s.Tags {
+		if !anchoredTagRegexp.MatchString(tag) {
+			errs = append(errs, fmt.Errorf("tags: %q is not a valid tag", tag))
+		}
+	}
+	errs = append(errs, validateAnnotations("annotations", s.Annotations)...)
+	for _, platform := range s.RequirePlatforms {
+		if _, err := platforms.Parse(platform); err != nil {
+			errs = append(errs, fmt.Errorf("requirePlatforms: %s", err))
+		}
+	}
+
+	if len(s.Manifests) == 0 {
+		errs = append(errs, fmt.Errorf("manifests: at least one manifest is required"))
+	}
+	seen := make(map[string]bool)
+	for i, m := range s.Manifests {
+		field := fmt.Sprintf("manifests[%d]", i)
+		if m.Image == "" {
+			errs = append(errs, fmt.Errorf("%s: image is required", field))
+			continue
+		}
+		if _, err := reference.ParseNamed(m.Image); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", field, err))
+		}
+		if seen[m.Image] {
+			errs = append(errs, fmt.Errorf("%s: image %s is listed more than once", field, m.Image))
+		}
+		seen[m.Image] = true
+		errs = append(errs, validateAnnotations(field+".annotations", m.Annotations)...)
+	}
+
+	return errs.errOrNil()
+}
+
+func validateAnnotations(field string, annotations map[string]string) []error {
+	var errs []error
+	for key := range annotations {
+		if key == "" {
+			errs = append(errs, fmt.Errorf("%s: annotation keys must not be empty", field))
+		}
+	}
+	return errs
+}
+
+// PlanSpec validates a spec and plans the manifest list it describes.
+func PlanSpec(a *AuthInfo, opts *CreateOptions, s *Spec) (*Plan, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	specOpts := CreateOptions{}
+	if opts != nil {
+		specOpts = *opts
+	}
+	if len(s.RequirePlatforms) > 0 && len(specOpts.RequirePlatforms) == 0 {
+		// the platforms given to the command win over the ones of the spec
+		required, err := platforms.ParseAll(s.RequirePlatforms)
+		if err != nil {
+			return nil, err
+		}
+		specOpts.RequirePlatforms = required
+	}
+	// so do the annotations given to the command
+	specOpts.Annotations = mergeAnnotations(s.Annotations, specOpts.Annotations)
+	opts = &specOpts
+
+	plan, err := PlanManifestList(a, opts, s.Image, s.Manifests)
+	if err != nil {
+		return nil, err
+	}
+	if err := plan.AddTags(s.Tags...); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// overridePlatform returns p with the fields set in override replaced, and
+// aliases like aarch64 replaced by their canonical names.
+func overridePlatform(p, override manifestlist.PlatformSpec) manifestlist.PlatformSpec {
+	if override.OS != "" {
+		p.OS = override.OS
+	}
+	if override.Architecture != "" {
+		p.Architecture = override.Architecture
+	}
+	if override.Variant != "" {
+		p.Variant = override.Variant
+	}
+	if override.OSVersion != "" {
+		p.OSVersion = override.OSVersion
+	}
+	if len(override.OSFeatures) > 0 {
+		p.OSFeatures = override.OSFeatures
+	}
+	if len(override.Features) > 0 {
+		p.Features = override.Features
+	}
+	return platforms.Canonical(p)
+}
@@ -0,0 +1,71 @@
+package manifest
+
+// SpecSchema is the JSON Schema of a spec file, for editors and external
+// validators. It must be kept in sync with Spec.
+const SpecSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/sakeven/manifest/spec.schema.json",
+  "title": "Manifest list spec",
+  "type": "object",
+  "required": ["image", "manifests"],
+  "additionalProperties": false,
+  "properties": {
+    "image": {
+      "description": "Reference the manifest list is pushed to",
+      "type": "string",
+      "minLength": 1
+    },
+    "tags": {
+      "description": "Additional tags the manifest list is pushed to, in the repository of image",
+      "type": "array",
+      "items": {"type": "string", "pattern": "^[\\w][\\w.-]{0,127}$"}
+    },
+    "annotations": {"$ref": "#/definitions/annotations"},
+    "requirePlatforms": {
+      "description": "Platforms the manifest list must have, no more and no less",
+      "type": "array",
+      "items": {"type": "string", "pattern": "^[^/]+/[^/]+(/[^/]+)?$"}
+    },
+    "manifests": {
+      "description": "Source images of the manifest list",
+      "type": "array",
+      "minItems": 1,
+      "items": {"$ref": "#/definitions/manifest"}
+    }
+  },
+  "definitions": {
+    "annotations": {
+      "type": "object",
+      "propertyNames": {"minLength": 1},
+      "additionalProperties": {"type": "string"}
+    },
+    "manifest": {
+      "type": "object",
+      "required": ["image"],
+      "additionalProperties": false,
+      "properties": {
+        "image": {
+          "description": "Reference of the source image",
+          "type": "string",
+          "minLength": 1
+        },
+        "platform": {"$ref": "#/definitions/platform"},
+        "annotations": {"$ref": "#/definitions/annotations"}
+      }
+    },
+    "platform": {
+      "description": "Overrides the platform detected from the image",
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "architecture": {"type": "string"},
+        "os": {"type": "string"},
+        "os.version": {"type": "string"},
+        "os.features": {"type": "array", "items": {"type": "string"}},
+        "variant": {"type": "string"},
+        "features": {"type": "array", "items": {"type": "string"}}
+      }
+    }
+  }
+}
+`
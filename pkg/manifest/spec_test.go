@@ -0,0 +1,74 @@
+package manifest
+
+import (
+	"testing"
+)
+
+func TestParseSpec(t *testing.T) {
+	yamlSpec := `
+image: registry.example.com/app:1.4.2
+tags: ["1.4", latest]
+manifests:
+  - image: registry.example.com/app:1.4.2-amd64
+  - image: registry.example.com/app:1.4.2-arm64
+    platform:
+      variant: v8
+    annotations:
+      org.opencontainers.image.revision: abc
+`
+	jsonSpec := `{
+  "image": "registry.example.com/app:1.4.2",
+  "tags": ["1.4", "latest"],
+  "manifests": [
+    {"image": "registry.example.com/app:1.4.2-amd64"},
+    {"image": "registry.example.com/app:1.4.2-arm64", "platform": {"variant": "v8"},
+     "annotations": {"org.opencontainers.image.revision": "abc"}}
+  ]
+}`
+
+	for _, content := range []string{yamlSpec, jsonSpec} {
+		spec, err := ParseSpec([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := spec.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if len(spec.Tags) != 2 || len(spec.Manifests) != 2 {
+			t.Fatalf("unexpected spec %#v", spec)
+		}
+		m := spec.Manifests[1]
+		if m.Platform == nil || m.Platform.Variant != "v8" {
+			t.Errorf("platform override of %s not parsed: %#v", m.Image, m.Platform)
+		}
+		if m.Annotations["org.opencontainers.image.revision"] != "abc" {
+			t.Errorf("annotations of %s not parsed: %#v", m.Image, m.Annotations)
+		}
+	}
+}
+
+func TestParseSpecUnknownField(t *testing.T) {
+	if _, err := ParseSpec([]byte("image: app\nmanifest: []\n")); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
+
+func TestSpecValidate(t *testing.T) {
+	spec := &Spec{
+		Tags: []string{"-bad"},
+		Manifests: []Source{
+			{Image: "app:amd64"},
+			{Image: "app:amd64"},
+			{},
+		},
+	}
+	err := spec.Validate()
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+	// missing image, invalid tag, duplicated manifest and manifest without image
+	if len(errs) != 4 {
+		t.Errorf("expected 4 errors, got %d: %s", len(errs), errs)
+	}
+}
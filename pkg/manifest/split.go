@@ -0,0 +1,141 @@
+package manifest
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// DefaultSplitTagTemplate is the default template of the tags a manifest list
+// is split into.
+const DefaultSplitTagTemplate = "{{.Tag}}-{{.Arch}}{{.Variant}}"
+
+// SplitOptions holds options about how a manifest list is split.
+type SplitOptions struct {
+	// TagTemplate is a text/template of the tag of each entry. It can use
+	// .Tag, .OS, .Arch, .Variant and .OSVersion.
+	TagTemplate string
+
+	// Repository is the repository the entries are pushed to, on the same
+	// registry. The repository of the manifest list is used if it is empty.
+	Repository string
+
+	// Force overwrites tags which already point to a different manifest.
+	Force bool
+}
+
+// splitTag holds the fields a split tag template can use.
+type splitTag struct {
+	Tag       string
+	OS        string
+	Arch      string
+	Variant   string
+	OSVersion string
+}
+
+// Split pushes the manifest of each entry of a manifest list under its own
+// tag, so that it can be pulled by clients which do not support manifest
+// lists. The result of every tag is returned, along with an error if any of
+// them failed.
+func Split(a *AuthInfo, listImage string, opts *SplitOptions) ([]TagResult, error) {
+	tagTemplate := opts.TagTemplate
+	if tagTemplate == "" {
+		tagTemplate = DefaultSplitTagTemplate
+	}
+	tmpl, err := template.New("tag").Option("missingkey=error").Parse(tagTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag template: %s", err)
+	}
+
+	ref, err := reference.ParseNamed(listImage)
+	if err != nil {
+		return nil, err
+	}
+	dstRef := reference.TrimNamed(ref)
+	if opts.Repository != "" {
+		if dstRef, err = reference.ParseNamed(opts.Repository); err != nil {
+			return nil, err
+		}
+		if !reference.IsNameOnly(dstRef) {
+			return nil, fmt.Errorf("repository %s must not have a tag or digest", opts.Repository)
+		}
+		if !isSameHub(ref, dstRef) {
+			return nil, fmt.Errorf("cannot split into a repository on a different registry than the manifest list")
+		}
+	}
+
+	clients := newClientPool(a)
+	r, err := clients.get(ref.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	repo, tag := Parse(ref)
+	imgs, err := Inspect(r, repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(imgs) == 0 || !IsManifestList(imgs[0].MediaType) {
+		return nil, fmt.Errorf("%s is not a manifest list", listImage)
+	}
+
+	// work out all tags first, so that nothing is pushed if any is invalid
+	entries := imgs[1:]
+	tags := make([]string, len(entries))
+	seen := make(map[string]bool)
+	for i, img := range entries {
+		var buf bytes.Buffer
+		err := tmpl.Execute(&buf, splitTag{
+			Tag:       tag,
+			OS:        img.Platform.OS,
+			Arch:      img.Platform.Architecture,
+			Variant:   img.Platform.Variant,
+			OSVersion: img.Platform.OSVersion,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid tag template: %s", err)
+		}
+		tags[i] = buf.String()
+		if !anchoredTagRegexp.MatchString(tags[i]) {
+			return nil, fmt.Errorf("entry %s gives the invalid tag %q", platforms.Format(img.Platform), tags[i])
+		}
+		if seen[tags[i]] {
+			return nil, fmt.Errorf("several entries give the tag %q, the tag template must tell their platforms apart", tags[i])
+		}
+		seen[tags[i]] = true
+	}
+
+	dstRepo := dstRef.RemoteName()
+	if !isSameRepo(ref, dstRef) {
+		var mounts []blobMount
+		for _, img := range entries {
+			for _, layer := range img.References {
+				mounts = append(mounts, blobMount{FromRepo: repo, Digest: layer})
+			}
+		}
+		if err := mountBlobs(r, dstRef, mounts); err != nil {
+			return nil, fmt.Errorf("failed to mount blobs for cross-repository push: %s", err)
+		}
+	}
+
+	createOpts := &CreateOptions{Force: opts.Force}
+	var (
+		results []TagResult
+		failed  Errors
+	)
+	for i, img := range entries {
+		result := pushEntry(r, createOpts, dstRepo, tags[i], img)
+		if result.Err != nil {
+			failed = append(failed, result.Err)
+		} else {
+			log.Debugf("Entry %s is pushed to %s:%s", platforms.Format(img.Platform), dstRepo, tags[i])
+		}
+		results = append(results, result)
+	}
+	return results, failed.errOrNil()
+}
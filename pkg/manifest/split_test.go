@@ -0,0 +1,90 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+)
+
+func TestSplitRefusesTags(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	putFakeList(r, "app", "1.4", amd64, arm64)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	tests := []struct {
+		template string
+		err      string
+	}{
+		{"{{.Tag}}-{{.Platform}}", "invalid tag template"},
+		{"{{.Tag}}-{{.Arch", "invalid tag template"},
+		{"{{.Tag}}/{{.Arch}}", `entry linux/amd64 gives the invalid tag "1.4/amd64"`},
+		{"{{.Tag}}-{{.OS}}", `several entries give the tag "1.4-linux"`},
+	}
+	for _, test := range tests {
+		_, err := Split(auth, r.Host()+"/app:1.4", &SplitOptions{TagTemplate: test.template})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Split(%q) = %v, want %q", test.template, err, test.err)
+		}
+	}
+	if len(r.writes) != 0 {
+		t.Errorf("refused splits wrote %v", r.writes)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	armv7 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7"}
+	layer := r.putBlob("app", schema2.MediaTypeLayer, []byte("layer"))
+	entries := []manifestlist.ManifestDescriptor{
+		{Descriptor: r.putImage("app", "", fakeConfig(amd64, nil), layer), Platform: amd64},
+		{Descriptor: putFakeImage(r, "app", "", armv7), Platform: armv7},
+	}
+	putFakeListOf(r, "app", "1.4", entries...)
+
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	results, err := Split(auth, r.Host()+"/app:1.4", &SplitOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := []string{"1.4-amd64", "1.4-armv7"}
+	for i, result := range results {
+		if result.Tag != tags[i] || result.Digest != entries[i].Digest {
+			t.Errorf("result %d is %s@%s, want %s@%s", i, result.Tag, result.Digest, tags[i], entries[i].Digest)
+		}
+		if r.digest("app", tags[i]) != entries[i].Digest {
+			t.Errorf("tag %s does not point to the entry %s", tags[i], entries[i].Digest)
+		}
+	}
+	if mounts := r.written("mount="); len(mounts) != 0 {
+		t.Errorf("split in the same repository mounted %v", mounts)
+	}
+
+	results, err = Split(auth, r.Host()+"/app:1.4", &SplitOptions{TagTemplate: "{{.OS}}-{{.Arch}}{{.Variant}}", Repository: r.Host() + "/app-legacy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags = []string{"linux-amd64", "linux-armv7"}
+	for i, result := range results {
+		if result.Tag != tags[i] || r.digest("app-legacy", tags[i]) != entries[i].Digest {
+			t.Errorf("tag %s does not point to the entry %s", tags[i], entries[i].Digest)
+		}
+	}
+	if !r.hasBlob("app-legacy", layer.Digest) {
+		t.Error("the layer was not mounted into app-legacy")
+	}
+	if mounts := r.written("mount="); len(mounts) != 3 {
+		t.Errorf("mounts %v, want the layer and both configurations", mounts)
+	}
+	if uploads := r.written("/blobs/uploads/1"); len(uploads) != 0 {
+		t.Errorf("blobs were uploaded instead of mounted: %v", uploads)
+	}
+}
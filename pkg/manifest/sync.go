@@ -0,0 +1,266 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+	"github.com/sakeven/manifest/pkg/registry"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// SyncConfig describes repositories to mirror, read from a YAML or JSON file.
+type SyncConfig struct {
+	// Repositories are the repositories to mirror.
+	Repositories []SyncRepository `json:"repositories"`
+}
+
+// SyncRepository describes a repository to mirror into another one.
+type SyncRepository struct {
+	// Source is the repository to mirror, without a tag.
+	Source string `json:"source"`
+
+	// Destination is the repository the tags are copied to, without a tag.
+	Destination string `json:"destination"`
+
+	// Tags is a regular expression which the whole tag must match to be
+	// mirrored. All tags are mirrored if it is empty.
+	Tags string `json:"tags,omitempty"`
+
+	// MaxTags limits the mirrored tags to the highest ones in version
+	// order, like 1.10 after 1.9. All matching tags are mirrored if it is 0.
+	MaxTags int `json:"maxTags,omitempty"`
+
+	// Platforms are the platforms of the entries of manifest lists to
+	// mirror, like linux/amd64. All entries are mirrored if it is empty.
+	Platforms []string `json:"platforms,omitempty"`
+}
+
+// SyncReport is the summary of a sync.
+type SyncReport struct {
+	Started      time.Time    `json:"started"`
+	Finished     time.Time    `json:"finished"`
+	Repositories []SyncResult `json:"repositories"`
+}
+
+// SyncResult is the summary of the sync of one repository.
+type SyncResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+
+	// Copied are the tags which were missing or pointed to another digest.
+	Copied []string `json:"copied,omitempty"`
+
+	// UpToDate are the tags which already pointed to the same digest.
+	UpToDate []string `json:"upToDate,omitempty"`
+
+	// Failed maps the tags which could not be copied to their error.
+	Failed map[string]string `json:"failed,omitempty"`
+
+	// Error is set if the repository could not be synced at all.
+	Error string `json:"error,omitempty"`
+}
+
+// LoadSyncConfig reads a sync config from a YAML or JSON file.
+func LoadSyncConfig(path string) (*SyncConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := new(SyncConfig)
+	if err := decodeYAML(content, cfg); err != nil {
+		return nil, fmt.Errorf("invalid sync config %s: %s", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks a sync config, and reports every problem found.
+func (cfg *SyncConfig) Validate() error {
+	var errs Errors
+
+	if len(cfg.Repositories) == 0 {
+		errs = append(errs, fmt.Errorf("repositories: at least one repository is required"))
+	}
+	for i, repo := range cfg.Repositories {
+		field := fmt.Sprintf("repositories[%d]", i)
+		errs = append(errs, validateRepository(field+".source", repo.Source)...)
+		errs = append(errs, validateRepository(field+".destination", repo.Destination)...)
+		if _, err := repo.tagRegexp(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid tags: %s", field, err))
+		}
+		if repo.MaxTags < 0 {
+			errs = append(errs, fmt.Errorf("%s: maxTags must not be negative", field))
+		}
+		if _, err := platforms.ParseAll(repo.Platforms); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", field, err))
+		}
+	}
+
+	return errs.errOrNil()
+}
+
+func validateRepository(field, repo string) []error {
+	if repo == "" {
+		return []error{fmt.Errorf("%s: repository is required", field)}
+	}
+	ref, err := reference.ParseNamed(repo)
+	if err != nil {
+		return []error{fmt.Errorf("%s: %s", field, err)}
+	}
+	if !reference.IsNameOnly(ref) {
+		return []error{fmt.Errorf("%s: %s must not have a tag or digest", field, repo)}
+	}
+	return nil
+}
+
+func (repo *SyncRepository) tagRegexp() (*regexp.Regexp, error) {
+	if repo.Tags == "" {
+		return nil, nil
+	}
+	return regexp.Compile(`^(?:` + repo.Tags + `)$`)
+}
+
+// Sync copies the tags of every repository of a sync config which are missing
+// from, or point to another digest in, their destination repository. Tags
+// which point to another digest are overwritten. The report covers every
+// repository, even if some of them failed.
+func Sync(a *AuthInfo, cfg *SyncConfig) (*SyncReport, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	report := &SyncReport{Started: time.Now()}
+	clients := newClientPool(a)
+	var failed Errors
+	for _, repo := range cfg.Repositories {
+		result := syncRepository(clients, repo)
+		if result.Error != "" {
+			failed = append(failed, fmt.Errorf("sync of %s failed: %s", repo.Source, result.Error))
+		} else if len(result.Failed) > 0 {
+			failed = append(failed, fmt.Errorf("sync of %s failed for %d tags", repo.Source, len(result.Failed)))
+		}
+		report.Repositories = append(report.Repositories, result)
+	}
+	report.Finished = time.Now()
+	return report, failed.errOrNil()
+}
+
+func syncRepository(clients *clientPool, repo SyncRepository) SyncResult {
+	result := SyncResult{Source: repo.Source, Destination: repo.Destination}
+
+	// the config is validated, so none of these fail
+	srcRef, _ := reference.ParseNamed(repo.Source)
+	dstRef, _ := reference.ParseNamed(repo.Destination)
+	tagRegexp, _ := repo.tagRegexp()
+	selectors, _ := platforms.ParseAll(repo.Platforms)
+
+	c, err := newCopier(clients, srcRef, dstRef, &CopyOptions{Platforms: selectors, Force: true})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	srcTags, err := c.src.ListTags(c.srcRepo)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to list tags of %s: %s", repo.Source, err)
+		return result
+	}
+	tags := selectTags(srcTags, tagRegexp, repo.MaxTags)
+
+	dstTags, err := c.dst.ListTags(c.dstRepo)
+	if err != nil && !registry.IsNotFound(err) {
+		result.Error = fmt.Sprintf("failed to list tags of %s: %s", repo.Destination, err)
+		return result
+	}
+	existing := make(map[string]bool)
+	for _, tag := range dstTags {
+		existing[tag] = true
+	}
+
+	for _, tag := range tags {
+		// the digest of a manifest list changes when only some platforms are
+		// mirrored, so only the copy itself can tell it is up to date
+		if existing[tag] && len(selectors) == 0 {
+			same, err := sameDigest(c, tag)
+			if err == nil && same {
+				result.UpToDate = append(result.UpToDate, tag)
+				continue
+			}
+		}
+
+		copied := c.copyTag(tag, tag)
+		switch {
+		case copied.Err != nil:
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[tag] = copied.Err.Error()
+		case copied.Unchanged:
+			result.UpToDate = append(result.UpToDate, tag)
+		default:
+			log.Infof("Tag %s of %s is synced to %s", tag, repo.Source, copied.Digest)
+			result.Copied = append(result.Copied, tag)
+		}
+	}
+	return result
+}
+
+// sameDigest returns true if tag points to the same digest in the source and
+// destination repositories of c.
+func sameDigest(c *copier, tag string) (bool, error) {
+	src, err := currentDigest(c.src, c.srcRepo, tag)
+	if err != nil {
+		return false, err
+	}
+	dst, err := currentDigest(c.dst, c.dstRepo, tag)
+	if err != nil {
+		return false, err
+	}
+	return src != "" && src == dst, nil
+}
+
+// selectTags returns the tags matching re, in version order, keeping only the
+// highest max ones if max is not 0.
+func selectTags(tags []string, re *regexp.Regexp, max int) []string {
+	var selected []string
+	for _, tag := range tags {
+		if re == nil || re.MatchString(tag) {
+			selected = append(selected, tag)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		return lessVersion(selected[i], selected[j])
+	})
+	if max > 0 && len(selected) > max {
+		selected = selected[len(selected)-max:]
+	}
+	return selected
+}
+
+// versionPartRegexp splits a tag into runs of digits and of other characters.
+var versionPartRegexp = regexp.MustCompile(`[0-9]+|[^0-9]+`)
+
+// lessVersion compares tags by comparing their runs of digits as numbers, so
+// that 1.9 is before 1.10.
+func lessVersion(a, b string) bool {
+	pa, pb := versionPartRegexp.FindAllString(a, -1), versionPartRegexp.FindAllString(b, -1)
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		if pa[i] == pb[i] {
+			continue
+		}
+		na, errA := strconv.ParseUint(pa[i], 10, 64)
+		nb, errB := strconv.ParseUint(pb[i], 10, 64)
+		if errA == nil && errB == nil && na != nb {
+			return na < nb
+		}
+		return pa[i] < pb[i]
+	}
+	return len(pa) < len(pb)
+}
@@ -0,0 +1,39 @@
+package manifest
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSelectTags(t *testing.T) {
+	tags := []string{"latest", "1.10.0", "1.9.3", "1.9.12", "2.0.0-rc1", "1.2.0", "v1"}
+
+	got := selectTags(tags, regexp.MustCompile(`^(?:1\.[0-9]+\.[0-9]+)$`), 3)
+	want := []string{"1.9.3", "1.9.12", "1.10.0"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("selectTags() = %v, want %v", got, want)
+	}
+
+	if got := selectTags(tags, nil, 0); len(got) != len(tags) {
+		t.Errorf("selectTags() without filters = %v, want all tags", got)
+	}
+}
+
+func TestSyncConfigValidate(t *testing.T) {
+	cfg := &SyncConfig{Repositories: []SyncRepository{
+		{Source: "registry.example.com/app", Destination: "mirror.example.com/app", Tags: `1\..*`, Platforms: []string{"linux/amd64"}},
+	}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid config: %s", err)
+	}
+
+	cfg = &SyncConfig{Repositories: []SyncRepository{
+		{Source: "registry.example.com/app:1.4", Tags: "(", MaxTags: -1, Platforms: []string{"linux"}},
+	}}
+	err := cfg.Validate()
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 5 {
+		t.Errorf("invalid config gives %v, want 5 errors", err)
+	}
+}
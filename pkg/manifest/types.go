@@ -1,8 +1,87 @@
 package manifest
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
 // AuthInfo holds information about how manifest-tool should connect and authenticate to the docker registry
 type AuthInfo struct {
 	Username  string
 	Password  string
 	DockerCfg string
 }
+
+// Formats of manifest lists.
+const (
+	// FormatDocker is the docker manifest list format.
+	FormatDocker = "docker"
+	// FormatOCI is the OCI image index format.
+	FormatOCI = "oci"
+)
+
+// DefaultJobs is the default number of source images inspected concurrently.
+const DefaultJobs = 4
+
+// CreateOptions holds options about how a manifest list is created.
+type CreateOptions struct {
+	// Jobs limits how many source images are inspected concurrently.
+	Jobs int
+
+	// KeepOrder keeps the entries of the manifest list in the order of the
+	// source images, instead of sorting them by platform.
+	KeepOrder bool
+
+	// Force overwrites tags which already point to a different manifest.
+	Force bool
+
+	// IfDigest only overwrites the target tag if it currently points to this
+	// digest. Additional tags follow the usual overwrite rules.
+	IfDigest string
+
+	// Format is the format of the manifest list, FormatDocker or FormatOCI.
+	// If empty, an OCI image index is created when all entries are OCI image
+	// manifests, and a docker manifest list otherwise.
+	Format string
+
+	// RequirePlatforms are the platforms the manifest list must have, no more
+	// and no less, if set. A manifest list with other platforms is refused.
+	RequirePlatforms []manifestlist.PlatformSpec
+
+	// Annotations are set on the index, and ManifestAnnotations on its
+	// entries matching their platform. Annotations need an OCI image index,
+	// which is created unless another format is asked for.
+	Annotations         map[string]string
+	ManifestAnnotations []ManifestAnnotation
+
+	// Provenance sets the provenance annotations on the index: the creation
+	// time, the references of the source images, and the source and revision
+	// the images are labelled with.
+	Provenance bool
+
+	// Created is the creation time of the provenance annotations. If zero,
+	// the creation time of the newest source image is used.
+	Created time.Time
+}
+
+func (opts *CreateOptions) validate() error {
+	switch opts.Format {
+	case "", FormatDocker, FormatOCI:
+	default:
+		return fmt.Errorf("unknown manifest list format %q, expected %s or %s", opts.Format, FormatDocker, FormatOCI)
+	}
+	for key := range opts.Annotations {
+		if key == "" {
+			return fmt.Errorf("annotation keys must not be empty")
+		}
+	}
+	if opts.IfDigest != "" {
+		if _, err := digest.Parse(opts.IfDigest); err != nil {
+			return fmt.Errorf("invalid digest %s: %s", opts.IfDigest, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package manifest
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/sakeven/manifest/pkg/platforms"
+	"github.com/sakeven/manifest/pkg/reference"
+	"github.com/sakeven/manifest/pkg/registry"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+// VerifyOptions holds options about how a manifest list is verified.
+type VerifyOptions struct {
+	// Layers checks that every layer of every entry exists in the registry.
+	Layers bool
+
+	// Jobs is the number of entries verified at a time.
+	Jobs int
+}
+
+// VerifyReport is the result of the verification of a manifest list.
+type VerifyReport struct {
+	Image   string        `json:"image"`
+	Digest  digest.Digest `json:"digest"`
+	Entries []EntryReport `json:"entries"`
+}
+
+// EntryReport is the result of the verification of an entry of a manifest
+// list.
+type EntryReport struct {
+	Platform string        `json:"platform"`
+	Digest   digest.Digest `json:"digest"`
+	Problems []string      `json:"problems,omitempty"`
+}
+
+// OK returns true if no entry has problems.
+func (r *VerifyReport) OK() bool {
+	for _, entry := range r.Entries {
+		if len(entry.Problems) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Verify checks that every entry of a manifest list matches the manifest it
+// references: the manifest must exist with the declared size, digest and
+// media type, and its image configuration must have the declared platform.
+// The error is only set if the manifest list itself cannot be verified, the
+// problems of the entries are in the report.
+func Verify(a *AuthInfo, listImage string, opts *VerifyOptions) (*VerifyReport, error) {
+	ref, err := reference.ParseNamed(listImage)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newClientPool(a).get(ref.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	repo, tag := Parse(ref)
+	list, err := r.FetchManifest(repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := listEntries(list)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", listImage, err)
+	}
+	_, payload, err := list.Payload()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := opts.Jobs
+	if jobs <= 0 {
+		jobs = DefaultJobs
+	}
+	report := &VerifyReport{
+		Image:   listImage,
+		Digest:  digest.FromBytes(payload),
+		Entries: make([]EntryReport, len(entries)),
+	}
+	var (
+		sem = make(chan struct{}, jobs)
+		wg  sync.WaitGroup
+	)
+	for i, entry := range entries {
+		wg.Add(1)
+		go func(i int, entry manifestlist.ManifestDescriptor) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			report.Entries[i] = EntryReport{
+				Platform: platforms.Format(entry.Platform),
+				Digest:   entry.Digest,
+			}
+			for _, err := range verifyEntry(r, repo, entry, opts.Layers) {
+				report.Entries[i].Problems = append(report.Entries[i].Problems, err.Error())
+			}
+		}(i, entry)
+	}
+	wg.Wait()
+	return report, nil
+}
+
+// verifyEntry returns every problem of an entry of a manifest list.
+func verifyEntry(r *registry.Client, repo string, entry manifestlist.ManifestDescriptor, layers bool) []error {
+	m, err := r.FetchManifest(repo, entry.Digest.String())
+	if registry.IsNotFound(err) {
+		return []error{fmt.Errorf("manifest %s does not exist", entry.Digest)}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("fetch of manifest %s failed: %s", entry.Digest, err)}
+	}
+
+	var errs Errors
+	mediaType, payload, err := m.Payload()
+	if err != nil {
+		return []error{err}
+	}
+	if dgst := digest.FromBytes(payload); dgst != entry.Digest {
+		errs = append(errs, fmt.Errorf("digest of the manifest is %s", dgst))
+	}
+	if size := int64(len(payload)); size != entry.Size {
+		errs = append(errs, fmt.Errorf("size is %d, but the manifest is %d bytes", entry.Size, size))
+	}
+	if mediaType != entry.MediaType {
+		errs = append(errs, fmt.Errorf("media type is %s, but the manifest is %s", entry.MediaType, mediaType))
+	}
+
+	config, err := fetchImageConfig(r, repo, m)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("image configuration: %s", err))
+	} else if err := checkPlatform(entry.Platform, config); err != nil {
+		errs = append(errs, err)
+	}
+
+	if layers {
+		for _, layer := range imageLayers(m) {
+			if IsForeignLayer(layer) {
+				continue
+			}
+			exists, err := r.BlobExists(repo, layer.Digest.String())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("check of layer %s failed: %s", layer.Digest, err))
+			} else if !exists {
+				errs = append(errs, fmt.Errorf("layer %s does not exist", layer.Digest))
+			}
+		}
+	}
+	return errs
+}
+
+// CheckImagePlatforms checks that a manifest list has exactly the required
+// platforms, see CheckPlatformMatrix. The platforms of the manifest list are
+// returned even if the check fails.
+func CheckImagePlatforms(a *AuthInfo, listImage string, required []manifestlist.PlatformSpec) ([]manifestlist.PlatformSpec, error) {
+	ref, err := reference.ParseNamed(listImage)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newClientPool(a).get(ref.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	repo, tag := Parse(ref)
+	list, err := r.FetchManifest(repo, tag)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := listEntries(list)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", listImage, err)
+	}
+	actual := make([]manifestlist.PlatformSpec, len(entries))
+	for i, entry := range entries {
+		actual[i] = entry.Platform
+	}
+	return actual, CheckPlatformMatrix(required, actual)
+}
@@ -0,0 +1,105 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestVerify(t *testing.T) {
+	r := newFakeRegistry(t)
+	defer r.Close()
+
+	amd64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"}
+	arm64 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm64"}
+	s390x := manifestlist.PlatformSpec{OS: "linux", Architecture: "s390x"}
+	ppc64le := manifestlist.PlatformSpec{OS: "linux", Architecture: "ppc64le"}
+	armv7 := manifestlist.PlatformSpec{OS: "linux", Architecture: "arm", Variant: "v7", OSVersion: "1"}
+	i386 := manifestlist.PlatformSpec{OS: "linux", Architecture: "386"}
+
+	layer := r.putBlob("app", schema2.MediaTypeLayer, []byte("layer"))
+	good := r.putImage("app", "", fakeConfig(amd64, nil), layer)
+
+	missing := good
+	missing.Digest = digest.FromString("missing")
+
+	// a manifest served under a digest which is not its own, and declared
+	// with a wrong size
+	mismatched := putFakeImage(r, "app", "", s390x)
+	r.mu.Lock()
+	r.manifests["app@"+digest.FromString("other").String()] = r.manifests["app@"+mismatched.Digest.String()]
+	r.mu.Unlock()
+	mismatched.Digest = digest.FromString("other")
+	mismatched.Size++
+
+	mediaType := putFakeImage(r, "app", "", ppc64le)
+	mediaType.MediaType = ocischema.MediaTypeImageManifest
+
+	platform := putFakeImage(r, "app", "", manifestlist.PlatformSpec{OS: "windows", Architecture: "arm64", Variant: "v8", OSVersion: "2"})
+
+	missingLayer := r.putImage("app", "", fakeConfig(i386, nil), distribution.Descriptor{
+		MediaType: schema2.MediaTypeLayer,
+		Size:      13,
+		Digest:    digest.FromString("missing layer"),
+	})
+
+	putFakeListOf(r, "app", "1.4",
+		manifestlist.ManifestDescriptor{Descriptor: good, Platform: amd64},
+		manifestlist.ManifestDescriptor{Descriptor: missing, Platform: arm64},
+		manifestlist.ManifestDescriptor{Descriptor: mismatched, Platform: s390x},
+		manifestlist.ManifestDescriptor{Descriptor: mediaType, Platform: ppc64le},
+		manifestlist.ManifestDescriptor{Descriptor: platform, Platform: armv7},
+		manifestlist.ManifestDescriptor{Descriptor: missingLayer, Platform: i386},
+	)
+
+	want := [][]string{
+		nil,
+		{"manifest " + missing.Digest.String() + " does not exist"},
+		{"digest of the manifest is ", "size is "},
+		{"media type is " + ocischema.MediaTypeImageManifest},
+		{`os is "linux", but the image is "windows"; architecture is "arm", but the image is "arm64"; variant is "v7", but the image is "v8"; os.version is "1", but the image is "2"`},
+		nil,
+	}
+	auth := &AuthInfo{Username: "user", Password: "password"}
+	for _, layers := range []bool{false, true} {
+		if layers {
+			want[5] = []string{"layer " + digest.FromString("missing layer").String() + " does not exist"}
+		}
+		report, err := Verify(auth, r.Host()+"/app:1.4", &VerifyOptions{Layers: layers, Jobs: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.OK() {
+			t.Errorf("layers %t: report is OK", layers)
+		}
+		if len(report.Entries) != len(want) {
+			t.Fatalf("layers %t: %d entries, want %d", layers, len(report.Entries), len(want))
+		}
+		for i, entry := range report.Entries {
+			if len(entry.Problems) != len(want[i]) {
+				t.Errorf("layers %t: problems of %s are %q, want %q", layers, entry.Platform, entry.Problems, want[i])
+				continue
+			}
+			for j, problem := range entry.Problems {
+				if !strings.HasPrefix(problem, want[i][j]) {
+					t.Errorf("layers %t: problem of %s is %q, want %q", layers, entry.Platform, problem, want[i][j])
+				}
+			}
+		}
+	}
+
+	putFakeListOf(r, "app", "good", manifestlist.ManifestDescriptor{Descriptor: good, Platform: amd64})
+	report, err := Verify(auth, r.Host()+"/app:good", &VerifyOptions{Layers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("report of a healthy list is not OK: %+v", report.Entries)
+	}
+}
@@ -0,0 +1,85 @@
+package manifest
+
+import (
+	"fmt"
+
+	"github.com/sakeven/manifest/pkg/reference"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+// PlanWrap inspects a single image and plans pushing a manifest list with a
+// single entry for it, to target or, if target is empty, in place of the
+// image. The platform of the entry is read from the image configuration, and
+// the fields set in platform override it.
+//
+// When wrapping in place, unless forced or given another expected digest, the
+// plan only overwrites the tag if it still points to the image.
+func PlanWrap(a *AuthInfo, opts *CreateOptions, img, target string, platform *manifestlist.PlatformSpec) (*Plan, error) {
+	if opts == nil {
+		opts = &CreateOptions{}
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	srcRef, err := reference.ParseNamed(img)
+	if err != nil {
+		return nil, err
+	}
+	inPlace := target == ""
+	if inPlace {
+		if _, ok := srcRef.(reference.Canonical); ok {
+			return nil, fmt.Errorf("cannot wrap %s in place, it is referenced by digest", img)
+		}
+		target = img
+	}
+	targetRef, err := reference.ParseNamed(target)
+	if err != nil {
+		return nil, err
+	}
+	if !isSameHub(srcRef, targetRef) {
+		return nil, fmt.Errorf("cannot wrap an image into a different registry")
+	}
+
+	plan := newPlan(a, opts, targetRef)
+	r, err := plan.clients.get(srcRef.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	repo, tagOrDigest := Parse(srcRef)
+	imgs, err := Inspect(r, repo, tagOrDigest)
+	if err != nil {
+		return nil, fmt.Errorf("inspect of image %s failed with error: %v", img, err)
+	}
+	if len(imgs) > 0 && IsManifestList(imgs[0].MediaType) {
+		return nil, fmt.Errorf("image %s is already a manifest list", img)
+	}
+	if len(imgs) != 1 {
+		return nil, fmt.Errorf("unsupported manifest %s", img)
+	}
+
+	config, err := fetchImageConfig(r, repo, imgs[0].Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("fetch of image configuration of %s failed: %s", img, err)
+	}
+	src := sourceImage{
+		Source: Source{Image: img, Platform: platform},
+		Ref:    srcRef,
+		Image:  imgs[0],
+	}
+	src.Image.Platform = config.Platform()
+	if platform != nil {
+		src.Image.Platform = overridePlatform(src.Image.Platform, *platform)
+	}
+
+	if inPlace {
+		// compare-then-set, so that a concurrent push is not lost
+		plan.expectDigest(tagOrDigest, src.Image.Digest)
+	}
+	if err := plan.setManifests(plan.addSources([]sourceImage{src})); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
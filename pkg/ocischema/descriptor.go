@@ -0,0 +1,77 @@
+// Package ocischema implements the OCI image manifest and image index, which
+// the vendored docker distribution does not support yet. The types follow the
+// schema2 and manifestlist packages of docker distribution.
+package ocischema
+
+import (
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	// MediaTypeImageManifest specifies the mediaType for OCI image manifests.
+	MediaTypeImageManifest = "application/vnd.oci.image.manifest.v1+json"
+
+	// MediaTypeImageIndex specifies the mediaType for OCI image indexes.
+	MediaTypeImageIndex = "application/vnd.oci.image.index.v1+json"
+
+	// MediaTypeImageConfig specifies the mediaType for OCI image
+	// configurations.
+	MediaTypeImageConfig = "application/vnd.oci.image.config.v1+json"
+
+	// MediaTypeImageLayer is the mediaType used for uncompressed layers.
+	MediaTypeImageLayer = "application/vnd.oci.image.layer.v1.tar"
+
+	// MediaTypeImageLayerGzip is the mediaType used for gzipped layers.
+	MediaTypeImageLayerGzip = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+	// MediaTypeImageLayerNonDistributable is the mediaType used for
+	// uncompressed layers which must not be pushed to registries.
+	MediaTypeImageLayerNonDistributable = "application/vnd.oci.image.layer.nondistributable.v1.tar"
+
+	// MediaTypeImageLayerNonDistributableGzip is the mediaType used for
+	// gzipped layers which must not be pushed to registries.
+	MediaTypeImageLayerNonDistributableGzip = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+)
+
+// Descriptor describes targeted content. Unlike distribution.Descriptor, it
+// can carry annotations and a platform.
+type Descriptor struct {
+	// MediaType describe the type of the content.
+	MediaType string `json:"mediaType,omitempty"`
+
+	// Size in bytes of content.
+	Size int64 `json:"size,omitempty"`
+
+	// Digest uniquely identifies the content.
+	Digest digest.Digest `json:"digest,omitempty"`
+
+	// URLs contains the source URLs of this content.
+	URLs []string `json:"urls,omitempty"`
+
+	// Annotations contains arbitrary metadata about the content.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Platform describes the platform which the image in the manifest runs
+	// on. It is only set for the manifests of an index.
+	Platform *manifestlist.PlatformSpec `json:"platform,omitempty"`
+}
+
+// Descriptor returns the distribution descriptor of d.
+func (d Descriptor) Descriptor() distribution.Descriptor {
+	return distribution.Descriptor{
+		MediaType: d.MediaType,
+		Size:      d.Size,
+		Digest:    d.Digest,
+		URLs:      d.URLs,
+	}
+}
+
+func distributionDescriptors(descriptors []Descriptor) []distribution.Descriptor {
+	dependencies := make([]distribution.Descriptor, len(descriptors))
+	for i := range descriptors {
+		dependencies[i] = descriptors[i].Descriptor()
+	}
+	return dependencies
+}
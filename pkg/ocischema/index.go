@@ -0,0 +1,121 @@
+package ocischema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest"
+	"github.com/opencontainers/go-digest"
+)
+
+// IndexSchemaVersion provides a pre-initialized version structure for OCI
+// image indexes.
+var IndexSchemaVersion = manifest.Versioned{
+	SchemaVersion: 2,
+	MediaType:     MediaTypeImageIndex,
+}
+
+func init() {
+	indexFunc := func(b []byte) (distribution.Manifest, distribution.Descriptor, error) {
+		m := new(DeserializedIndex)
+		err := m.UnmarshalJSON(b)
+		if err != nil {
+			return nil, distribution.Descriptor{}, err
+		}
+
+		dgst := digest.FromBytes(b)
+		return m, distribution.Descriptor{Digest: dgst, Size: int64(len(b)), MediaType: MediaTypeImageIndex}, err
+	}
+	err := distribution.RegisterManifestSchema(MediaTypeImageIndex, indexFunc)
+	if err != nil {
+		panic(fmt.Sprintf("Unable to register manifest: %s", err))
+	}
+}
+
+// Index references manifests for various platforms.
+type Index struct {
+	manifest.Versioned
+
+	// Manifests references the manifests of the index.
+	Manifests []Descriptor `json:"manifests"`
+
+	// Annotations contains arbitrary metadata about the index.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// References returns the distribution descriptors for the referenced image
+// manifests.
+func (m Index) References() []distribution.Descriptor {
+	return distributionDescriptors(m.Manifests)
+}
+
+// DeserializedIndex wraps Index with a copy of the original JSON.
+type DeserializedIndex struct {
+	Index
+
+	// canonical is the canonical byte representation of the Index.
+	canonical []byte
+}
+
+// FromDescriptors takes a slice of descriptors and annotations, and returns
+// a DeserializedIndex which contains the resulting index and its JSON
+// representation.
+func FromDescriptors(descriptors []Descriptor, annotations map[string]string) (*DeserializedIndex, error) {
+	m := Index{
+		Versioned:   IndexSchemaVersion,
+		Annotations: annotations,
+	}
+
+	m.Manifests = make([]Descriptor, len(descriptors), len(descriptors))
+	copy(m.Manifests, descriptors)
+
+	deserialized := DeserializedIndex{
+		Index: m,
+	}
+
+	var err error
+	deserialized.canonical, err = json.MarshalIndent(&m, "", "   ")
+	return &deserialized, err
+}
+
+// UnmarshalJSON populates a new Index struct from JSON data.
+func (m *DeserializedIndex) UnmarshalJSON(b []byte) error {
+	m.canonical = make([]byte, len(b), len(b))
+	// store index in canonical
+	copy(m.canonical, b)
+
+	// Unmarshal canonical JSON into Index object
+	var index Index
+	if err := json.Unmarshal(m.canonical, &index); err != nil {
+		return err
+	}
+	// the mediaType field is optional in OCI indexes
+	if index.MediaType == "" {
+		index.MediaType = MediaTypeImageIndex
+	}
+	if index.MediaType != MediaTypeImageIndex {
+		return fmt.Errorf("mediaType in index should be '%s' not '%s'", MediaTypeImageIndex, index.MediaType)
+	}
+
+	m.Index = index
+
+	return nil
+}
+
+// MarshalJSON returns the contents of canonical. If canonical is empty,
+// marshals the inner contents.
+func (m *DeserializedIndex) MarshalJSON() ([]byte, error) {
+	if len(m.canonical) > 0 {
+		return m.canonical, nil
+	}
+
+	return nil, errors.New("JSON representation not initialized in DeserializedIndex")
+}
+
+// Payload returns the raw content of the index. The contents can be used to
+// calculate the content identifier.
+func (m DeserializedIndex) Payload() (string, []byte, error) {
+	return m.MediaType, m.canonical, nil
+}
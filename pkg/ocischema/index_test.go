@@ -0,0 +1,61 @@
+package ocischema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestIndexRoundTrip(t *testing.T) {
+	descs := []Descriptor{
+		{
+			MediaType:   MediaTypeImageManifest,
+			Size:        500,
+			Digest:      digest.FromString("amd64"),
+			Annotations: map[string]string{"org.opencontainers.image.revision": "abc"},
+			Platform:    &manifestlist.PlatformSpec{OS: "linux", Architecture: "amd64"},
+		},
+	}
+	index, err := FromDescriptors(descs, map[string]string{"org.opencontainers.image.source": "https://example.com/app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, payload, err := index.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mediaType != MediaTypeImageIndex {
+		t.Errorf("media type is %s", mediaType)
+	}
+
+	var parsed DeserializedIndex
+	if err := json.Unmarshal(payload, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	_, parsedPayload, _ := parsed.Payload()
+	if string(parsedPayload) != string(payload) {
+		t.Errorf("payload changed:\n%s\n%s", payload, parsedPayload)
+	}
+	if len(parsed.References()) != 1 || parsed.Manifests[0].Platform.Architecture != "amd64" {
+		t.Errorf("unexpected manifests %#v", parsed.Manifests)
+	}
+	if parsed.Annotations["org.opencontainers.image.source"] == "" || parsed.Manifests[0].Annotations["org.opencontainers.image.revision"] != "abc" {
+		t.Errorf("annotations not parsed: %#v", parsed.Index)
+	}
+}
+
+func TestManifestWithoutMediaType(t *testing.T) {
+	var m DeserializedManifest
+	payload := []byte(`{"schemaVersion":2,"config":{"mediaType":"` + MediaTypeImageConfig + `","size":2,"digest":"` + digest.FromString("{}").String() + `"},"layers":[]}`)
+	if err := json.Unmarshal(payload, &m); err != nil {
+		t.Fatal(err)
+	}
+	if mediaType, _, _ := m.Payload(); mediaType != MediaTypeImageManifest {
+		t.Errorf("media type is %q", mediaType)
+	}
+	if len(m.References()) != 1 {
+		t.Errorf("unexpected references %#v", m.References())
+	}
+}
@@ -0,0 +1,161 @@
+// Package platforms parses, formats, normalizes and matches platforms written
+// as os/arch[/variant], the way container runtimes select images.
+package platforms
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+// Parse parses a platform written as os/arch[/variant]. Aliases like aarch64,
+// x86_64 or armhf are normalized, but default variants are not filled in, so
+// that linux/arm64 stays linux/arm64.
+func Parse(s string) (manifestlist.PlatformSpec, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return manifestlist.PlatformSpec{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+	}
+	for _, part := range parts {
+		if part == "" {
+			return manifestlist.PlatformSpec{}, fmt.Errorf("invalid platform %q, expected os/arch[/variant]", s)
+		}
+	}
+
+	p := manifestlist.PlatformSpec{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return Canonical(p), nil
+}
+
+// ParseAll parses a list of platforms.
+func ParseAll(ss []string) ([]manifestlist.PlatformSpec, error) {
+	platforms := make([]manifestlist.PlatformSpec, 0, len(ss))
+	for _, s := range ss {
+		p, err := Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, p)
+	}
+	return platforms, nil
+}
+
+// Format formats a platform as os/arch[/variant].
+func Format(p manifestlist.PlatformSpec) string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+// Canonical returns p with its OS and architecture aliases replaced by their
+// canonical names, e.g. aarch64 by arm64 and armhf by arm/v7. Unlike
+// Normalize, default variants are not filled in.
+func Canonical(p manifestlist.PlatformSpec) manifestlist.PlatformSpec {
+	return normalize(p, false)
+}
+
+// Normalize returns p with its OS and architecture aliases replaced by their
+// canonical names, and the default variant filled in: v7 for arm and v8 for
+// arm64.
+func Normalize(p manifestlist.PlatformSpec) manifestlist.PlatformSpec {
+	return normalize(p, true)
+}
+
+func normalize(p manifestlist.PlatformSpec, defaults bool) manifestlist.PlatformSpec {
+	p.OS = strings.ToLower(p.OS)
+	if p.OS == "macos" {
+		p.OS = "darwin"
+	}
+
+	arch, variant := strings.ToLower(p.Architecture), strings.ToLower(p.Variant)
+	switch arch {
+	case "i386", "i686", "x86":
+		arch = "386"
+	case "x86_64", "x86-64", "amd64":
+		arch = "amd64"
+	case "aarch64", "arm64":
+		arch = "arm64"
+		if variant == "8" {
+			variant = "v8"
+		}
+		if defaults && variant == "" {
+			variant = "v8"
+		}
+	case "armhf":
+		arch, variant = "arm", "v7"
+	case "armel":
+		arch, variant = "arm", "v6"
+	case "arm":
+		switch variant {
+		case "5", "6", "7", "8":
+			variant = "v" + variant
+		case "":
+			if defaults {
+				variant = "v7"
+			}
+		}
+	}
+	p.Architecture, p.Variant = arch, variant
+	return p
+}
+
+// Match returns true if p matches the platform selector. Both are normalized
+// first. The variant only has to match if the selector has one, e.g. linux/arm
+// matches every arm variant, while linux/arm64/v8 matches linux/arm64.
+func Match(selector, p manifestlist.PlatformSpec) bool {
+	s, n := Normalize(selector), Normalize(p)
+	if s.OS != n.OS || s.Architecture != n.Architecture {
+		return false
+	}
+	return Canonical(selector).Variant == "" || s.Variant == n.Variant
+}
+
+// armVariants are the arm variants, newest first. A CPU of a variant can run
+// the code of the older ones.
+var armVariants = []string{"v8", "v7", "v6", "v5"}
+
+// Compatible returns the platforms a host of platform p can run, best first.
+// The host platform itself comes first, then its fallbacks: an arm64 host
+// can run arm/v8 and older arm variants, and an arm host older variants.
+func Compatible(p manifestlist.PlatformSpec) []manifestlist.PlatformSpec {
+	p = Normalize(p)
+	compatible := []manifestlist.PlatformSpec{p}
+
+	var variants []string
+	switch {
+	case p.Architecture == "arm64" && p.Variant == "v8":
+		variants = armVariants
+	case p.Architecture == "arm":
+		for i, v := range armVariants {
+			if v == p.Variant {
+				variants = armVariants[i+1:]
+				break
+			}
+		}
+	}
+	for _, v := range variants {
+		fallback := p
+		fallback.Architecture, fallback.Variant = "arm", v
+		compatible = append(compatible, fallback)
+	}
+	return compatible
+}
+
+// BestMatch returns the index of the candidate a host of platform p runs best,
+// trying the platforms it is compatible with in order, or -1 if it can run
+// none of them. Among candidates of the same platform, the first one wins.
+func BestMatch(p manifestlist.PlatformSpec, candidates []manifestlist.PlatformSpec) int {
+	for _, c := range Compatible(p) {
+		for i, candidate := range candidates {
+			if Match(c, candidate) {
+				return i
+			}
+		}
+	}
+	return -1
+}
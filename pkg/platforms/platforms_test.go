@@ -0,0 +1,116 @@
+package platforms
+
+import (
+	"testing"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]string{
+		"linux/amd64":     "linux/amd64",
+		"linux/x86_64":    "linux/amd64",
+		"linux/aarch64":   "linux/arm64",
+		"linux/arm64/8":   "linux/arm64/v8",
+		"linux/armhf":     "linux/arm/v7",
+		"linux/arm/6":     "linux/arm/v6",
+		"Linux/ARM":       "linux/arm",
+		"windows/amd64":   "windows/amd64",
+		"linux/ppc64le":   "linux/ppc64le",
+		"linux/arm64/v8":  "linux/arm64/v8",
+		"linux/i386":      "linux/386",
+		"darwin/arm64":    "darwin/arm64",
+		"linux/riscv64/x": "linux/riscv64/x",
+	}
+	for s, want := range tests {
+		p, err := Parse(s)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %s", s, err)
+			continue
+		}
+		if got := Format(p); got != want {
+			t.Errorf("Parse(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"", "linux", "linux/", "linux/arm/v7/x"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected an error parsing %q", s)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"linux/arm":      "linux/arm/v7",
+		"linux/arm64":    "linux/arm64/v8",
+		"linux/aarch64":  "linux/arm64/v8",
+		"linux/armel":    "linux/arm/v6",
+		"linux/amd64":    "linux/amd64",
+		"linux/arm/v6":   "linux/arm/v6",
+		"linux/s390x":    "linux/s390x",
+		"linux/x86_64":   "linux/amd64",
+		"windows/x86_64": "windows/amd64",
+	}
+	for s, want := range tests {
+		p, _ := Parse(s)
+		if got := Format(Normalize(p)); got != want {
+			t.Errorf("Normalize(%s) = %s, want %s", s, got, want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		selector, platform string
+		match              bool
+	}{
+		{"linux/arm", "linux/arm/v6", true},
+		{"linux/arm/v7", "linux/arm", true},
+		{"linux/arm/v7", "linux/arm/v6", false},
+		{"linux/armhf", "linux/arm/v7", true},
+		{"linux/arm64/v8", "linux/arm64", true},
+		{"linux/aarch64", "linux/arm64/v8", true},
+		{"linux/amd64", "linux/x86_64", true},
+		{"linux/amd64", "windows/amd64", false},
+		{"linux/arm64", "linux/arm/v8", false},
+	}
+	for _, test := range tests {
+		selector, _ := Parse(test.selector)
+		p, _ := Parse(test.platform)
+		if Match(selector, p) != test.match {
+			t.Errorf("Match(%s, %s) = %t, want %t", test.selector, test.platform, !test.match, test.match)
+		}
+	}
+}
+
+func TestBestMatch(t *testing.T) {
+	candidates := func(ss ...string) []manifestlist.PlatformSpec {
+		ps, err := ParseAll(ss)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ps
+	}
+	tests := []struct {
+		host       string
+		candidates []manifestlist.PlatformSpec
+		want       int
+	}{
+		{"linux/arm64", candidates("linux/amd64", "linux/arm/v7", "linux/arm64"), 2},
+		{"linux/arm64", candidates("linux/amd64", "linux/arm/v6", "linux/arm/v7"), 2},
+		{"linux/arm64/v8", candidates("linux/arm/v6", "linux/arm/v8"), 1},
+		{"linux/arm/v7", candidates("linux/arm64", "linux/arm/v6"), 1},
+		{"linux/arm/v6", candidates("linux/arm/v7"), -1},
+		{"linux/amd64", candidates("linux/arm64", "windows/amd64"), -1},
+		{"linux/x86_64", candidates("linux/arm64", "linux/amd64"), 1},
+	}
+	for _, test := range tests {
+		host, _ := Parse(test.host)
+		if got := BestMatch(host, test.candidates); got != test.want {
+			t.Errorf("BestMatch(%s) = %d, want %d", test.host, got, test.want)
+		}
+	}
+}
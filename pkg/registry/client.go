@@ -20,6 +20,24 @@ type Client struct {
 
 var defaultTimeout = 600 * time.Second
 
+// StatusError is returned when the registry responds with an unexpected
+// status code.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code %d, body %s", e.Code, e.Body)
+}
+
+// IsNotFound returns true if err reports that the requested manifest, blob or
+// repository does not exist.
+func IsNotFound(err error) bool {
+	e, ok := err.(*StatusError)
+	return ok && e.Code == http.StatusNotFound
+}
+
 // NewClient creates a new Registry client with a default timeout.
 func NewClient(apiPath, username, password string) *Client {
 	return NewClientTimeout(apiPath, username, password, defaultTimeout)
@@ -67,7 +85,7 @@ func (r *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
 
 	if c := resp.StatusCode; !(200 <= c && c <= 299) {
 		content, _ := ioutil.ReadAll(resp.Body)
-		return nil, fmt.Errorf("status code %d, body %s", c, string(content))
+		return nil, &StatusError{Code: c, Body: string(content)}
 	}
 
 	if v != nil {
@@ -80,6 +98,22 @@ func (r *Client) do(req *http.Request, v interface{}) (*http.Response, error) {
 	return resp, err
 }
 
+// doStream sends an API request and returns the API response, whose body is
+// left open for the caller to read and close.
+func (r *Client) doStream(req *http.Request) (*http.Response, error) {
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if c := resp.StatusCode; !(200 <= c && c <= 299) {
+		defer resp.Body.Close()
+		content, _ := ioutil.ReadAll(resp.Body)
+		return nil, &StatusError{Code: c, Body: string(content)}
+	}
+	return resp, nil
+}
+
 // newRequest creates an API request. A relative URL can be provided in urlStr,
 // in which case it is resolved relative to the BaseURL of the Client.
 // Relative URLs should always be specified without a preceding slash.  If
@@ -4,6 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/sakeven/manifest/pkg/ocischema"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/manifestlist"
@@ -36,8 +42,7 @@ func (r *Client) FetchManifest(repository, tag string) (distribution.Manifest, e
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Accept", manifestlist.MediaTypeManifestList)
-	req.Header.Add("Accept", schema2.MediaTypeManifest)
+	setAcceptHeaders(req)
 
 	bf := new(bytes.Buffer)
 	resp, err := r.do(req, bf)
@@ -47,7 +52,7 @@ func (r *Client) FetchManifest(repository, tag string) (distribution.Manifest, e
 
 	var m distribution.Manifest
 
-	contentType := resp.Header.Get("Content-Type")
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
 	switch contentType {
 	case schema1.MediaTypeManifest, schema1.MediaTypeSignedManifest:
 		m = &schema1.SignedManifest{}
@@ -55,12 +60,51 @@ func (r *Client) FetchManifest(repository, tag string) (distribution.Manifest, e
 		m = &schema2.DeserializedManifest{}
 	case manifestlist.MediaTypeManifestList:
 		m = &manifestlist.DeserializedManifestList{}
+	case ocischema.MediaTypeImageManifest:
+		m = &ocischema.DeserializedManifest{}
+	case ocischema.MediaTypeImageIndex:
+		m = &ocischema.DeserializedIndex{}
+	default:
+		return nil, fmt.Errorf("unsupported manifest media type %q", contentType)
 	}
 
 	err = json.Unmarshal(bf.Bytes(), m)
 	return m, err
 }
 
+// setAcceptHeaders sets the manifest media types accepted from distribution.
+func setAcceptHeaders(req *http.Request) {
+	req.Header.Set("Accept", manifestlist.MediaTypeManifestList)
+	req.Header.Add("Accept", schema2.MediaTypeManifest)
+	req.Header.Add("Accept", ocischema.MediaTypeImageIndex)
+	req.Header.Add("Accept", ocischema.MediaTypeImageManifest)
+}
+
+// HeadManifest gets the descriptor of a manifest from distribution, without
+// fetching its content. The digest is empty if the registry does not return
+// it.
+func (r *Client) HeadManifest(repository, tag string) (distribution.Descriptor, error) {
+	req, err := r.newRequest("HEAD", fmt.Sprintf("/v2/%s/manifests/%s", repository, tag), nil)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+	setAcceptHeaders(req)
+
+	resp, err := r.do(req, nil)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	desc := distribution.Descriptor{
+		MediaType: resp.Header.Get("Content-Type"),
+		Size:      resp.ContentLength,
+	}
+	if dgstHeader := resp.Header.Get("Docker-Content-Digest"); dgstHeader != "" {
+		desc.Digest, err = digest.Parse(dgstHeader)
+	}
+	return desc, err
+}
+
 // PullBlob pulls blob
 func (r *Client) PullBlob(repository, sha string) ([]byte, error) {
 	req, err := r.newRequest("GET", fmt.Sprintf("/v2/%s/blobs/%s", repository, sha), nil)
@@ -92,3 +136,115 @@ func (r *Client) MountBlob(repository string, digest string, fromRepo string) (s
 	}
 	return resp.Header.Get("Location"), nil
 }
+
+// HeadBlob gets the size and digest of a blob without pulling it.
+func (r *Client) HeadBlob(repository, sha string) (distribution.Descriptor, error) {
+	req, err := r.newRequest("HEAD", fmt.Sprintf("/v2/%s/blobs/%s", repository, sha), nil)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	resp, err := r.do(req, nil)
+	if err != nil {
+		return distribution.Descriptor{}, err
+	}
+
+	desc := distribution.Descriptor{Size: resp.ContentLength}
+	if dgstHeader := resp.Header.Get("Docker-Content-Digest"); dgstHeader != "" {
+		desc.Digest, err = digest.Parse(dgstHeader)
+	}
+	return desc, err
+}
+
+// BlobExists returns true if a blob exists in a repository.
+func (r *Client) BlobExists(repository, sha string) (bool, error) {
+	_, err := r.HeadBlob(repository, sha)
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetBlob gets a blob as a stream, along with its size. The caller must close
+// the returned reader.
+func (r *Client) GetBlob(repository, sha string) (io.ReadCloser, int64, error) {
+	req, err := r.newRequest("GET", fmt.Sprintf("/v2/%s/blobs/%s", repository, sha), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := r.doStream(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Body, resp.ContentLength, nil
+}
+
+// PushBlob uploads a blob of known digest and size to a repository, in a
+// single request.
+func (r *Client) PushBlob(repository, sha string, size int64, content io.Reader) error {
+	req, err := r.newRequest("POST", fmt.Sprintf("/v2/%s/blobs/uploads/", repository), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Length", "0")
+
+	resp, err := r.do(req, nil)
+	if err != nil {
+		return err
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return fmt.Errorf("upload of blob %s has no location: %s", sha, err)
+	}
+	query := location.Query()
+	query.Set("digest", sha)
+	location.RawQuery = query.Encode()
+
+	req, err = r.newRequest("PUT", location.String(), content)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	_, err = r.do(req, nil)
+	return err
+}
+
+// ListTags lists all tags of a repository.
+func (r *Client) ListTags(repository string) ([]string, error) {
+	var tags []string
+	url := fmt.Sprintf("/v2/%s/tags/list", repository)
+	for url != "" {
+		req, err := r.newRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		page := struct {
+			Tags []string `json:"tags"`
+		}{}
+		resp, err := r.do(req, &page)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+		url = nextLink(resp.Header.Get("Link"))
+	}
+	return tags, nil
+}
+
+// nextLink returns the URL of the next page from a Link header like
+// `</v2/app/tags/list?n=100&last=1.4>; rel="next"`.
+func nextLink(link string) string {
+	if !strings.Contains(link, `rel="next"`) {
+		return ""
+	}
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start == -1 || end < start {
+		return ""
+	}
+	return link[start+1 : end]
+}